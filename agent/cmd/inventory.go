@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/inventory"
+	"agent/pkg/pubsub"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var inventoryOpts struct {
+	json       bool
+	noPackages bool
+}
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Exibe o inventário de hardware e software coletado pelo agente",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+
+		if err != nil {
+			fmt.Println("Erro ao carregar configuração:", err)
+			return
+		}
+
+		journal, err := pubsub.NewImplantacaoJournal(
+			filepath.Join(cfg.DataDir, "implantacoes.json"),
+		)
+
+		if err != nil {
+			fmt.Println("Erro ao abrir histórico de implantações:", err)
+			return
+		}
+
+		inv := inventory.Collect(cmd.Context(), journal, !inventoryOpts.noPackages)
+
+		if inventoryOpts.json {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(inv)
+			return
+		}
+
+		printInventory(inv)
+	},
+}
+
+func init() {
+	flags := inventoryCmd.Flags()
+
+	flags.BoolVar(&inventoryOpts.json, "json", false, "imprime o inventário em JSON")
+	flags.BoolVar(&inventoryOpts.noPackages, "no-packages", false, "não lista os pacotes instalados")
+
+	rootCmd.AddCommand(inventoryCmd)
+}
+
+func printInventory(inv inventory.Inventory) {
+	section := func(title string) {
+		fmt.Println()
+		fmt.Println(foregroundStyle.Render(title))
+	}
+
+	section("Sistema")
+	fmt.Printf("  Hostname:   %s\n", inv.Host.Hostname)
+	fmt.Printf("  Sistema:    %s %s (%s)\n", inv.Host.Platform, inv.Host.PlatformVersion, inv.Host.OS)
+	fmt.Printf("  Kernel:     %s %s\n", inv.Host.KernelVersion, inv.Host.KernelArch)
+	fmt.Printf("  Uptime:     %s\n", time.Duration(inv.Host.Uptime)*time.Second)
+
+	section("Hardware")
+	fmt.Printf("  CPU:        %s (%d núcleos, %d threads)\n", inv.Cpu.Model, inv.Cpu.Cores, inv.Cpu.Threads)
+	fmt.Printf("  Memória:    %s (swap %s)\n", formatBytes(inv.Memory.Total), formatBytes(inv.Memory.SwapTotal))
+
+	section("Discos")
+	for _, d := range inv.Disks {
+		fmt.Printf("  %-20s %-20s %-8s %s / %s\n", d.Mountpoint, d.Device, d.Fstype, formatBytes(d.Used), formatBytes(d.Total))
+	}
+
+	section("Rede")
+	for _, i := range inv.Network {
+		state := "down"
+
+		if i.Up {
+			state = "up"
+		}
+
+		fmt.Printf("  %-16s %-18s %-4s %s\n", i.Name, i.Mac, state, strings.Join(i.Addrs, ", "))
+	}
+
+	section("Implantações")
+	for _, r := range inv.Releases {
+		fmt.Printf("  #%-6d %-16s %-14s %s\n", r.IdImplantacao, r.Versao, r.Status, r.UpdatedAt.Local().Format(time.DateTime))
+	}
+
+	if len(inv.Packages) > 0 {
+		section(fmt.Sprintf("Pacotes (%d)", len(inv.Packages)))
+		for _, p := range inv.Packages {
+			fmt.Printf("  %-40s %s\n", p.Name, p.Version)
+		}
+	}
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package cmd
+
+import (
+	"agent/pkg/pty"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var ptyLauncherJob string
+
+// Executado pelo winpty no lugar do shell das sessões pty, para iniciar o
+// shell dentro do job object da sessão
+var ptyLauncherCmd = &cobra.Command{
+	Use:    pty.LauncherCommand + " --job <nome> -- <shell> [args...]",
+	Short:  "Inicia o shell de uma sessão pty dentro do job object da sessão",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		code, err := pty.RunLauncher(ptyLauncherJob, args)
+
+		if err != nil {
+			fmt.Println("Erro ao iniciar o shell:", err)
+			os.Exit(1)
+		}
+
+		os.Exit(code)
+	},
+}
+
+func init() {
+	ptyLauncherCmd.Flags().StringVar(&ptyLauncherJob, "job", "", "job object da sessão")
+
+	rootCmd.AddCommand(ptyLauncherCmd)
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/daemon"
+	"agent/pkg/secret"
+	"agent/pkg/system"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// Nome do serviço no sistema de inicialização
+const serviceName = "vrdeploy"
+
+var serviceOpts struct {
+	init   string
+	user   string
+	config string
+	json   bool
+}
+
+// Usuário do sudo dono da configuração usada pelo serviço, quando ela foi
+// localizada na pasta dele
+var serviceConfigOwner *user.User
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Gerencia o serviço do vrdeploy no sistema de inicialização",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Instala o agente como serviço do sistema e o inicia",
+	Run: func(cmd *cobra.Command, args []string) {
+		initSystem, spec := serviceSetup()
+
+		cfg, err := config.Load()
+
+		if err != nil {
+			fmt.Println("Erro ao carregar configuração:", err)
+			os.Exit(1)
+		}
+
+		err = useServiceSecrets(cfg)
+
+		if err != nil {
+			fmt.Println("Erro ao preparar os segredos para o serviço:", err)
+			os.Exit(1)
+		}
+
+		err = restoreConfigOwner(cfg)
+
+		if err != nil {
+			fmt.Println("Erro ao devolver os arquivos da configuração ao usuário:", err)
+			os.Exit(1)
+		}
+
+		err = initSystem.Install(spec)
+
+		if err != nil {
+			fmt.Println("Erro ao instalar serviço:", err)
+			os.Exit(1)
+		}
+
+		// O serviço do sistema substitui a inicialização por usuário
+		system.RemoveFromStartup()
+
+		fmt.Printf("Serviço %s instalado (%s)\n", spec.Name, initSystem.Name())
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Para e remove o serviço do vrdeploy",
+	Run: func(cmd *cobra.Command, args []string) {
+		initSystem, spec := serviceSetup()
+
+		err := initSystem.Uninstall(spec)
+
+		if errors.Is(err, daemon.ErrNotInstalled) {
+			fmt.Println("Serviço não instalado")
+			return
+		}
+
+		if err != nil {
+			fmt.Println("Erro ao remover serviço:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Serviço %s removido (%s)\n", spec.Name, initSystem.Name())
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Exibe o status do serviço do vrdeploy",
+	Long: "Exibe o status do serviço do vrdeploy.\n\n" +
+		"Códigos de saída:\n" +
+		"  0  serviço em execução\n" +
+		"  1  erro\n" +
+		"  3  serviço parado ou não instalado",
+	Run: func(cmd *cobra.Command, args []string) {
+		initSystem, spec := serviceSetup()
+
+		status, err := initSystem.Status(spec)
+
+		if err != nil {
+			fmt.Println("Erro ao consultar serviço:", err)
+			os.Exit(1)
+		}
+
+		if serviceOpts.json {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(status)
+		} else {
+			printServiceStatus(status)
+		}
+
+		if !status.Running {
+			os.Exit(3)
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{serviceInstallCmd, serviceUninstallCmd, serviceStatusCmd} {
+		cmd.Flags().StringVar(&serviceOpts.init, "init", "", "sistema de inicialização (systemd, openrc, sysv ou windows). Padrão: detectado")
+		serviceCmd.AddCommand(cmd)
+	}
+
+	serviceInstallCmd.Flags().StringVar(&serviceOpts.user, "user", "", "usuário que executa o agente. Padrão: root")
+	serviceInstallCmd.Flags().StringVar(&serviceOpts.config, "config", "", "arquivo de configuração criado pelo setup. Padrão: o do usuário que executou o sudo")
+	serviceStatusCmd.Flags().BoolVar(&serviceOpts.json, "json", false, "imprime o status em JSON")
+
+	rootCmd.AddCommand(serviceCmd)
+}
+
+// serviceSetup resolve o sistema de inicialização e a especificação do
+// serviço, finalizando o comando em caso de erro.
+func serviceSetup() (daemon.InitSystem, daemon.Spec) {
+	initSystem, err := daemon.Get(serviceOpts.init)
+
+	if err != nil {
+		fmt.Println("Erro ao detectar sistema de inicialização:", err)
+		os.Exit(1)
+	}
+
+	err = resolveServiceConfig()
+
+	if err != nil {
+		fmt.Println("Erro ao localizar configuração:", err)
+		os.Exit(1)
+	}
+
+	spec, err := serviceSpec()
+
+	if err != nil {
+		fmt.Println("Erro ao preparar serviço:", err)
+		os.Exit(1)
+	}
+
+	return initSystem, spec
+}
+
+// resolveServiceConfig aponta a configuração para a criada pelo setup. O
+// install é executado com sudo, e sem isso o caminho, o diretório de dados
+// e os segredos seriam os do root.
+func resolveServiceConfig() error {
+	path := serviceOpts.config
+
+	if path == "" && os.Getenv("VRDEPLOY_CONFIG") == "" {
+		sudoUser := os.Getenv("SUDO_USER")
+
+		if sudoUser == "" || sudoUser == "root" {
+			return nil
+		}
+
+		account, err := user.Lookup(sudoUser)
+
+		if err != nil {
+			return fmt.Errorf("usuário do sudo %s: %w", sudoUser, err)
+		}
+
+		path = config.UserPath(account.HomeDir)
+
+		// Sem setup feito por esse usuário, vale a configuração atual
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+
+		serviceConfigOwner = account
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	path, err := filepath.Abs(path)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(path)
+
+	if err != nil {
+		return fmt.Errorf("configuração do setup não encontrada: %w", err)
+	}
+
+	return config.SetPath(path)
+}
+
+func serviceSpec() (daemon.Spec, error) {
+	cfg, err := config.Load()
+
+	if err != nil {
+		return daemon.Spec{}, err
+	}
+
+	path, err := config.Path()
+
+	if err != nil {
+		return daemon.Spec{}, err
+	}
+
+	path, err = filepath.Abs(path)
+
+	if err != nil {
+		return daemon.Spec{}, err
+	}
+
+	executable, err := os.Executable()
+
+	if err != nil {
+		return daemon.Spec{}, err
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+
+	if err != nil {
+		return daemon.Spec{}, err
+	}
+
+	return daemon.Spec{
+		Name:        serviceName,
+		Description: "Agente do vrdeploy",
+		Executable:  executable,
+		Args:        []string{"start"},
+		User:        serviceOpts.user,
+		// O serviço é executado com outro usuário (root ou LocalSystem) e
+		// precisa encontrar a configuração criada pelo setup
+		Env: map[string]string{
+			"VRDEPLOY_CONFIG": path,
+		},
+		// Margem para o agente finalizar após o tempo de grace
+		StopTimeout: cfg.Connection.ShutdownGrace + 5,
+		LogPath:     filepath.Join("/var/log", serviceName+".log"),
+	}, nil
+}
+
+// useServiceSecrets move os segredos para o arquivo cifrado, já que o
+// chaveiro do usuário que executou o setup não é acessível pelo serviço.
+// Falha se nenhuma credencial chegar ao novo backend, o que acontece quando
+// o chaveiro do usuário do setup não pode ser lido daqui.
+func useServiceSecrets(cfg *config.Config) error {
+	if cfg.Secret.Backend != secret.BackendAuto && cfg.Secret.Backend != secret.BackendKeyring {
+		return nil
+	}
+
+	backend := cfg.Secret.Backend
+	cfg.Secret.Backend = secret.BackendEncryptedFile
+
+	// Open migra os segredos do chaveiro para o novo backend
+	store, err := secret.Open(cfg.Secret, cfg.DataDir)
+
+	if err != nil {
+		return err
+	}
+
+	if !hasCredential(store) {
+		cfg.Secret.Backend = backend
+
+		return errors.New("nenhuma credencial do agente foi migrada do chaveiro; " +
+			"execute o setup com secret.backend \"encrypted-file\" ou como o usuário do serviço")
+	}
+
+	return config.Save(cfg)
+}
+
+// restoreConfigOwner devolve ao usuário do sudo os arquivos da configuração
+// e do diretório de dados, que o install regrava como root dentro da pasta
+// dele. Diretórios fora da pasta do usuário são mantidos como estão. O
+// serviço executado como root continua com acesso aos arquivos.
+func restoreConfigOwner(cfg *config.Config) error {
+	if serviceConfigOwner == nil {
+		return nil
+	}
+
+	uid, err := strconv.Atoi(serviceConfigOwner.Uid)
+
+	if err != nil {
+		return err
+	}
+
+	gid, err := strconv.Atoi(serviceConfigOwner.Gid)
+
+	if err != nil {
+		return err
+	}
+
+	path, err := config.Path()
+
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{filepath.Dir(path), cfg.DataDir} {
+		rel, err := filepath.Rel(serviceConfigOwner.HomeDir, dir)
+
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+
+		err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			return os.Lchown(path, uid, gid)
+		})
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasCredential(store secret.SecretStore) bool {
+	for _, key := range []string{secret.PrivateKeyKey, secret.TokenKey} {
+		if _, err := store.Get(key); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printServiceStatus(status daemon.Status) {
+	yesNo := func(v bool) string {
+		if v {
+			return "sim"
+		}
+
+		return "não"
+	}
+
+	fmt.Printf("Sistema:     %s\n", status.Init)
+	fmt.Printf("Instalado:   %s\n", yesNo(status.Installed))
+
+	if !status.Installed {
+		return
+	}
+
+	if status.Path != "" {
+		fmt.Printf("Arquivo:     %s\n", status.Path)
+	}
+
+	fmt.Printf("Habilitado:  %s\n", yesNo(status.Enabled))
+	fmt.Printf("Em execução: %s\n", yesNo(status.Running))
+}
@@ -2,11 +2,21 @@ package cmd
 
 import (
 	"agent/pkg/api"
+	"agent/pkg/config"
+	"agent/pkg/credential"
+	"agent/pkg/daemon"
+	"agent/pkg/identity"
+	"agent/pkg/proxy"
 	"agent/pkg/pubsub"
 	"agent/pkg/secret"
 	"agent/pkg/system"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -15,171 +25,510 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Códigos de saída do setup, usados pelos scripts de provisionamento
+const (
+	exitAprovado  = 0
+	exitErro      = 1
+	exitRejeitado = 2
+	exitRemovido  = 3
+	exitTimeout   = 4
+	exitRede      = 5
+)
+
+type setupOptions struct {
+	server          string
+	enrollmentToken string
+	pdvId           string
+	noWait          bool
+	timeout         time.Duration
+	json            bool
+}
+
+var setupOpts setupOptions
+
+// setupResult é o resultado impresso com --json
+type setupResult struct {
+	// aprovado, pendente, rejeitado, removido, timeout ou erro
+	Status             string `json:"status"`
+	IdAgente           int    `json:"idAgente,omitempty"`
+	EnderecoMac        string `json:"enderecoMac,omitempty"`
+	SistemaOperacional string `json:"sistemaOperacional,omitempty"`
+	Fingerprint        string `json:"fingerprint,omitempty"`
+	Erro               string `json:"erro,omitempty"`
+}
+
+var (
+	foregroundStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF9200"))
+
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#FF9200")).
+			Padding(1, 2).
+			Margin(1, 0)
+)
+
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Realiza a configuração inicial do vrdeploy",
+	Long: "Realiza a configuração inicial do vrdeploy.\n\n" +
+		"Códigos de saída:\n" +
+		"  0  agente aprovado (ou cadastrado, com --no-wait)\n" +
+		"  1  erro\n" +
+		"  2  agente rejeitado\n" +
+		"  3  agente removido\n" +
+		"  4  tempo de espera esgotado\n" +
+		"  5  erro de rede",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Print("\033[H\033[2J")
+		interactive := !setupOpts.json
+
+		if interactive {
+			fmt.Print("\033[H\033[2J")
+
+			for _, line := range []string{
+				"",
+				"██╗   ██╗██████╗ ██████╗ ███████╗██████╗ ██╗      ██████╗ ██╗   ██╗",
+				"██║   ██║██╔══██╗██╔══██╗██╔════╝██╔══██╗██║     ██╔═══██╗╚██╗ ██╔╝",
+				"██║   ██║██████╔╝██║  ██║█████╗  ██████╔╝██║     ██║   ██║ ╚████╔╝ ",
+				"╚██╗ ██╔╝██╔══██╗██║  ██║██╔══╝  ██╔═══╝ ██║     ██║   ██║  ╚██╔╝  ",
+				" ╚████╔╝ ██║  ██║██████╔╝███████╗██║     ███████╗╚██████╔╝   ██║   ",
+				"  ╚═══╝  ╚═╝  ╚═╝╚═════╝ ╚══════╝╚═╝     ╚══════╝ ╚═════╝    ╚═╝   ",
+				"",
+			} {
+				fmt.Println(foregroundStyle.Render(line))
+				time.Sleep(200 * time.Millisecond)
+			}
+		}
 
-		foregroundStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF9200"))
+		result, code := runSetup(cmd.Context(), interactive)
 
-		for _, line := range []string{
-			"",
-			"██╗   ██╗██████╗ ██████╗ ███████╗██████╗ ██╗      ██████╗ ██╗   ██╗",
-			"██║   ██║██╔══██╗██╔══██╗██╔════╝██╔══██╗██║     ██╔═══██╗╚██╗ ██╔╝",
-			"██║   ██║██████╔╝██║  ██║█████╗  ██████╔╝██║     ██║   ██║ ╚████╔╝ ",
-			"╚██╗ ██╔╝██╔══██╗██║  ██║██╔══╝  ██╔═══╝ ██║     ██║   ██║  ╚██╔╝  ",
-			" ╚████╔╝ ██║  ██║██████╔╝███████╗██║     ███████╗╚██████╔╝   ██║   ",
-			"  ╚═══╝  ╚═╝  ╚═╝╚═════╝ ╚══════╝╚═╝     ╚══════╝ ╚═════╝    ╚═╝   ",
-			"",
-		} {
-			fmt.Println(foregroundStyle.Render(line))
-			time.Sleep(200 * time.Millisecond)
+		if setupOpts.json {
+			json.NewEncoder(os.Stdout).Encode(result)
+		} else if result.Erro != "" {
+			fmt.Println(result.Erro)
 		}
 
-		mac, err := system.MacAddress()
+		os.Exit(code)
+	},
+}
+
+func init() {
+	flags := setupCmd.Flags()
 
-		if err != nil {
-			fmt.Println("Erro ao obter o MAC address:", err)
-			return
-		}
+	flags.StringVar(&setupOpts.server, "server", "", "endereço do servidor do vrdeploy (ex: https://vrdeploy.empresa.com.br)")
+	flags.StringVar(&setupOpts.enrollmentToken, "enrollment-token", "", "token de cadastro pré-aprovado")
+	flags.StringVar(&setupOpts.pdvId, "pdv-id", "", "identificador do PDV")
+	flags.BoolVar(&setupOpts.noWait, "no-wait", false, "não aguarda a aprovação do agente")
+	flags.DurationVar(&setupOpts.timeout, "timeout", 0, "tempo máximo aguardando a aprovação (ex: 10m); zero aguarda indefinidamente")
+	flags.BoolVar(&setupOpts.json, "json", false, "imprime o resultado em JSON, sem animações")
 
-		info, err := host.Info()
+	rootCmd.AddCommand(setupCmd)
+}
 
-		if err != nil {
-			fmt.Println("Erro ao obter informações do sistema:", err)
-			return
-		}
+func setupError(message string, err error, code int) (setupResult, int) {
+	return setupResult{
+		Status: "erro",
+		Erro:   fmt.Sprintf("%s: %v", message, err),
+	}, code
+}
 
-		boxStyle := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#FF9200")).
-			Padding(1, 2).
-			Margin(1, 0)
+// errorCode classifica a falha de comunicação com o servidor.
+func errorCode(err error) int {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, identity.ErrRevoked):
+		return exitRejeitado
+	case errors.As(err, &netErr):
+		return exitRede
+	default:
+		return exitErro
+	}
+}
+
+func runSetup(ctx context.Context, interactive bool) (setupResult, int) {
+	cfg, err := config.Load()
 
+	if err != nil {
+		return setupError("Erro ao carregar configuração", err, exitErro)
+	}
+
+	if setupOpts.server != "" {
+		cfg.Server = setupOpts.server
+	}
+
+	err = api.Configure(cfg.Server)
+
+	if err != nil {
+		return setupError("Erro ao configurar servidor", err, exitErro)
+	}
+
+	err = proxy.Configure(cfg.Proxy)
+
+	if err != nil {
+		return setupError("Erro ao configurar proxy", err, exitErro)
+	}
+
+	store, err := secret.Open(cfg.Secret, cfg.DataDir)
+
+	if err != nil {
+		return setupError("Erro ao abrir armazenamento de segredos", err, exitErro)
+	}
+
+	secret.Use(store)
+
+	mac, err := system.MacAddress()
+
+	if err != nil {
+		return setupError("Erro ao obter o MAC address", err, exitErro)
+	}
+
+	info, err := host.Info()
+
+	if err != nil {
+		return setupError("Erro ao obter informações do sistema", err, exitErro)
+	}
+
+	fingerprint, err := system.MachineFingerprint()
+
+	if err != nil {
+		return setupError("Erro ao identificar a máquina", err, exitErro)
+	}
+
+	result := setupResult{
+		EnderecoMac:        mac,
+		SistemaOperacional: info.Platform + " " + info.PlatformVersion,
+		Fingerprint:        fingerprint.ID,
+	}
+
+	if interactive {
 		fmt.Println(
 			boxStyle.Render(
 				"Endereço MAC:", foregroundStyle.Render(mac),
-				"\nSistema Operacional:", foregroundStyle.Render(info.Platform+" "+info.PlatformVersion),
+				"\nSistema Operacional:", foregroundStyle.Render(result.SistemaOperacional),
+				"\nIdentificação:", foregroundStyle.Render(fingerprint.ID[:16]),
 			),
 		)
+	}
+
+	cadastro := startProgress(interactive, " Cadastrando agente...")
+
+	publicKey, privateKey, err := identity.Generate()
+
+	if err != nil {
+		cadastro.fail()
+		return setupError("Erro ao gerar chave do agente", err, exitErro)
+	}
+
+	resp, err := api.CadastrarAgente(api.CadastrarAgenteRequest{
+		EnderecoMac:        mac,
+		SistemaOperacional: result.SistemaOperacional,
+		ChavePublica:       identity.EncodePublicKey(publicKey),
+		IdPdv:              setupOpts.pdvId,
+		TokenCadastro:      setupOpts.enrollmentToken,
+		Fingerprint:        fingerprint,
+	})
+
+	if err != nil {
+		cadastro.fail()
+		return setupError("Erro ao cadastrar agente", err, errorCode(err))
+	}
+
+	if !resp.ChavePublicaRegistrada && resp.ChaveSecreta == "" {
+		cadastro.fail()
+		return setupError("Erro ao cadastrar agente", errors.New("servidor não retornou credenciais"), exitErro)
+	}
+
+	// A chave só passa a ser usada depois que o servidor confirma o
+	// registro da chave pública. Sem a confirmação, uma chave de um cadastro
+	// anterior também não vale mais
+	if resp.ChavePublicaRegistrada {
+		err = identity.Save(privateKey)
+	} else {
+		err = identity.DeleteKey()
+	}
+
+	if err != nil {
+		cadastro.fail()
+		return setupError("Erro ao salvar chave do agente", err, exitErro)
+	}
+
+	// O token só é usado quando o servidor não registrou a chave pública
+	if resp.ChavePublicaRegistrada {
+		err = secret.Delete()
+	} else {
+		err = secret.Set(resp.ChaveSecreta)
+	}
+
+	if err != nil {
+		cadastro.fail()
+		return setupError("Erro ao salvar chave secreta", err, exitErro)
+	}
 
-		cadastroAgenteSpinner := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		cadastroAgenteSpinner.Suffix = " Cadastrando agente..."
-		cadastroAgenteSpinner.FinalMSG = "✔  Agente cadastrado com sucesso!\n"
-		cadastroAgenteSpinner.Color("#FF9200")
-		cadastroAgenteSpinner.Start()
+	// O novo cadastro volta a permitir que o start conecte
+	err = credential.ClearRevoked(cfg.DataDir)
 
-		resp, err := api.CadastrarAgente(api.CadastrarAgenteRequest{
-			EnderecoMac:        mac,
-			SistemaOperacional: info.Platform + " " + info.PlatformVersion,
-		})
+	if err != nil {
+		cadastro.fail()
+		return setupError("Erro ao remover a marcação de agente revogado", err, exitErro)
+	}
+
+	// O start passa a usar o servidor informado no setup
+	if setupOpts.server != "" {
+		err = config.Save(cfg)
 
 		if err != nil {
-			fmt.Println("Erro ao cadastrar agente:", err)
-			return
+			cadastro.fail()
+			return setupError("Erro ao salvar configuração", err, exitErro)
 		}
+	}
 
-		err = secret.Set(resp.ChaveSecreta)
+	cadastro.done("✔  Agente cadastrado com sucesso!\n")
 
-		if err != nil {
-			fmt.Println("Erro ao salvar chave secreta:", err)
+	result.IdAgente = resp.ID
+
+	if resp.Situacao == "aprovado" {
+		return approved(result, interactive)
+	}
+
+	if setupOpts.noWait {
+		result.Status = "pendente"
+
+		if interactive {
+			fmt.Println(
+				boxStyle.Render(
+					"O agente aguarda a aprovação do administrador do sistema.\n" +
+						"Após aprovado, inicie o agente usando o comando `vrdeploy start`.",
+				),
+			)
+		}
+
+		return result, exitAprovado
+	}
+
+	return waitApproval(ctx, result, interactive)
+}
+
+// waitApproval aguarda o administrador aprovar, rejeitar ou remover o
+// agente, ou o tempo limite de --timeout.
+func waitApproval(ctx context.Context, result setupResult, interactive bool) (setupResult, int) {
+	aprovacao := startProgress(interactive, " Aguardando aprovação do agente...")
+
+	if setupOpts.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, setupOpts.timeout)
+		defer cancel()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	situacoes := make(chan pubsub.AgenteUpdatedPayload, 1)
+
+	ps := pubsub.New(
+		[]string{pubsub.AgenteUpdatedEvent},
+	)
+
+	ps.SetUrl(api.PubSubUrl())
+
+	pubsub.SubscribeTyped(ps, pubsub.AgenteUpdatedEvent, func(ctx context.Context, payload pubsub.AgenteUpdatedPayload) {
+		if payload.DeletedAt == nil && payload.Situacao != "aprovado" && payload.Situacao != "rejeitado" {
 			return
 		}
 
-		cadastroAgenteSpinner.Stop()
+		select {
+		case situacoes <- payload:
+		default:
+		}
+	})
 
-		agentePendenteSpinner := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		agentePendenteSpinner.Suffix = " Aguardando aprovação do agente..."
-		agentePendenteSpinner.FinalMSG = "✔  Agente aprovado com sucesso!"
-		agentePendenteSpinner.Color("#FF9200")
-		agentePendenteSpinner.Start()
+	// Com --json a saída padrão é reservada para o resultado
+	diagnostics := io.Writer(os.Stdout)
 
-		ps := pubsub.New(
-			[]string{pubsub.AgenteUpdatedEvent},
-		)
+	if !interactive {
+		diagnostics = os.Stderr
+	}
+
+	// O agente pode ter sido aprovado antes da conexão. O setup não atende
+	// as respostas de RPC, então a sincronização usa a rota HTTP
+	ps.OnConnect(func(connCtx context.Context) {
+		sincronizar(ctx, ps, nil, diagnostics)
+	})
 
-		ps.Subscribe(pubsub.AgenteUpdatedEvent, func(data string) {
-			var payload pubsub.AgenteUpdatedPayload
+	connErr := make(chan error, 1)
 
-			err := json.Unmarshal([]byte(data), &payload)
+	go func() {
+		for {
+			err := ps.Connect(ctx)
+
+			if ctx.Err() != nil {
+				return
+			}
 
 			if err != nil {
-				fmt.Println("Erro ao parsear payload:", err)
+				connErr <- err
 				return
 			}
 
-			if payload.DeletedAt != nil {
-				err := secret.Delete()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
 
-				if err != nil {
-					fmt.Println("Erro ao remover chave secreta:", err)
-					return
-				}
+	defer func() {
+		closeCtx, cancelClose := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelClose()
 
-				agentePendenteSpinner.FinalMSG = "✘  Agente removido!\n"
-				agentePendenteSpinner.Stop()
+		ps.Close(closeCtx, "setup finalizado")
+	}()
 
-				fmt.Println(
-					boxStyle.Render(
-						"O agente foi removido pelo administrador do sistema.\n" +
-							"Você pode tentar cadastrar o agente novamente usando o comando `vrdeploy setup`.",
-					),
-				)
-				return
-			}
+	select {
+	case payload := <-situacoes:
+		if payload.DeletedAt != nil {
+			aprovacao.done("✘  Agente removido!\n")
+			return removed(result, interactive)
+		}
 
-			switch payload.Situacao {
-			case "aprovado":
-				agentePendenteSpinner.Stop()
-				fmt.Println(
-					boxStyle.Render(
-						"Você já pode cancelar este processo (Ctrl+C) e usar o comando `vrdeploy start`.",
-					),
-				)
-				err = system.AddToStartup()
+		if payload.Situacao == "rejeitado" {
+			aprovacao.done("✘  Agente rejeitado!\n")
+			return rejected(result, interactive)
+		}
 
-				if err != nil {
-					fmt.Println("Erro ao adicionar o vrdeploy na inicialização do sistema:", err)
-				}
+		aprovacao.done("✔  Agente aprovado com sucesso!\n")
 
-				return
-			case "rejeitado":
-				err := secret.Delete()
-
-				if err != nil {
-					fmt.Println("Erro ao remover chave secreta:", err)
-					return
-				}
-
-				err = system.RemoveFromStartup()
-
-				if err != nil {
-					// Não é um erro crítico
-				}
-
-				agentePendenteSpinner.FinalMSG = "✘  Agente rejeitado!\n"
-				agentePendenteSpinner.Stop()
-				fmt.Println(
-					boxStyle.Render(
-						"O agente foi rejeitado pelo administrador do sistema.\n" +
-							"Você pode tentar cadastrar o agente novamente usando o comando `vrdeploy setup`.",
-					),
-				)
-				return
-			}
-		})
+		return approved(result, interactive)
+	case err := <-connErr:
+		aprovacao.fail()
 
-		err = ps.Connect()
+		code := errorCode(err)
+		result.Status = "erro"
+		result.Erro = fmt.Sprintf("Erro ao conectar ao serviço de pubsub: %v", err)
 
-		if err != nil {
-			fmt.Println("Erro ao conectar ao serviço de pubsub:", err)
-			return
+		// Credenciais rejeitadas enquanto aguardava: o agente foi removido
+		if code == exitRejeitado {
+			result.Status = "removido"
+			code = exitRemovido
 		}
-	},
+
+		return result, code
+	case <-ctx.Done():
+		aprovacao.fail()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Status = "timeout"
+			result.Erro = "Tempo de espera pela aprovação esgotado"
+
+			return result, exitTimeout
+		}
+
+		result.Status = "erro"
+		result.Erro = "Setup cancelado"
+
+		return result, exitErro
+	}
 }
 
-func init() {
-	rootCmd.AddCommand(setupCmd)
+func approved(result setupResult, interactive bool) (setupResult, int) {
+	result.Status = "aprovado"
+
+	// Com o serviço do sistema instalado, a inicialização por usuário
+	// executaria um segundo agente
+	if !daemon.Installed(daemon.Spec{Name: serviceName}) {
+		err := system.AddToStartup()
+
+		if err != nil && interactive {
+			fmt.Println("Erro ao adicionar o vrdeploy na inicialização do sistema:", err)
+		}
+	}
+
+	if interactive {
+		fmt.Println(
+			boxStyle.Render(
+				"O agente foi aprovado. Inicie o agente usando o comando `vrdeploy start`\n" +
+					"ou instale-o como serviço do sistema com `vrdeploy service install`.",
+			),
+		)
+	}
+
+	return result, exitAprovado
+}
+
+func rejected(result setupResult, interactive bool) (setupResult, int) {
+	result.Status = "rejeitado"
+
+	err := identity.Delete()
+
+	if err != nil {
+		result.Erro = fmt.Sprintf("Erro ao remover chave secreta: %v", err)
+	}
+
+	// Não é um erro crítico
+	system.RemoveFromStartup()
+
+	if interactive {
+		fmt.Println(
+			boxStyle.Render(
+				"O agente foi rejeitado pelo administrador do sistema.\n" +
+					"Você pode tentar cadastrar o agente novamente usando o comando `vrdeploy setup`.",
+			),
+		)
+	}
+
+	return result, exitRejeitado
+}
+
+func removed(result setupResult, interactive bool) (setupResult, int) {
+	result.Status = "removido"
+
+	err := identity.Delete()
+
+	if err != nil {
+		result.Erro = fmt.Sprintf("Erro ao remover chave secreta: %v", err)
+	}
+
+	if interactive {
+		fmt.Println(
+			boxStyle.Render(
+				"O agente foi removido pelo administrador do sistema.\n" +
+					"Você pode tentar cadastrar o agente novamente usando o comando `vrdeploy setup`.",
+			),
+		)
+	}
+
+	return result, exitRemovido
+}
+
+// progress exibe um spinner apenas no modo interativo.
+type progress struct {
+	spinner *spinner.Spinner
+}
+
+func startProgress(interactive bool, suffix string) *progress {
+	if !interactive {
+		return &progress{}
+	}
+
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Suffix = suffix
+	s.Color("#FF9200")
+	s.Start()
+
+	return &progress{spinner: s}
+}
+
+func (p *progress) done(message string) {
+	if p.spinner == nil {
+		return
+	}
+
+	p.spinner.FinalMSG = message
+	p.spinner.Stop()
+}
+
+func (p *progress) fail() {
+	p.done("")
 }
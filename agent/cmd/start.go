@@ -1,89 +1,579 @@
 package cmd
 
 import (
+	"agent/pkg/api"
+	"agent/pkg/command"
+	"agent/pkg/config"
+	"agent/pkg/credential"
+	"agent/pkg/daemon"
+	"agent/pkg/identity"
+	"agent/pkg/inventory"
+	"agent/pkg/metrics"
+	"agent/pkg/proxy"
 	"agent/pkg/pty"
 	"agent/pkg/pubsub"
+	"agent/pkg/secret"
+	"agent/pkg/service"
+	"agent/pkg/transfer"
+	"agent/pkg/tunnel"
+	"agent/pkg/update"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
+var errShutdown = errors.New("agente finalizando")
+
+// Código de saída do agente revogado, que não deve ser reiniciado pelo
+// sistema de inicialização
+const exitRevogado = daemon.ExitNoRestart
+
+// Método RPC do servidor que substitui a rota HTTP de sincronização
+const rpcSincronizar = "agente.sincronizar"
+
+// Tempo limite da sincronização, já que o cliente HTTP não tem um
+const sincronizarTimeout = 30 * time.Second
+
+const revokedMessage = "Agente revogado: as credenciais foram rejeitadas pelo servidor.\n" +
+	"Cadastre o agente novamente usando o comando `vrdeploy setup`."
+
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Realiza a inicialização do serviço do vrdeploy",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Print("\033[H\033[2J")
-
-		foregroundStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF9200"))
-
-		for _, line := range []string{
-			"",
-			"██╗   ██╗██████╗ ██████╗ ███████╗██████╗ ██╗      ██████╗ ██╗   ██╗",
-			"██║   ██║██╔══██╗██╔══██╗██╔════╝██╔══██╗██║     ██╔═══██╗╚██╗ ██╔╝",
-			"██║   ██║██████╔╝██║  ██║█████╗  ██████╔╝██║     ██║   ██║ ╚████╔╝ ",
-			"╚██╗ ██╔╝██╔══██╗██║  ██║██╔══╝  ██╔═══╝ ██║     ██║   ██║  ╚██╔╝  ",
-			" ╚████╔╝ ██║  ██║██████╔╝███████╗██║     ███████╗╚██████╔╝   ██║   ",
-			"  ╚═══╝  ╚═╝  ╚═╝╚═════╝ ╚══════╝╚═╝     ╚══════╝ ╚═════╝    ╚═╝   ",
-			"",
-		} {
-			fmt.Println(foregroundStyle.Render(line))
-			time.Sleep(200 * time.Millisecond)
+		// Antes de qualquer etapa que possa falhar, para que uma atualização
+		// que não inicializa seja revertida
+		update.CountStart()
+
+		// No Windows, quando iniciado pelo gerenciador de serviços, o agente
+		// é finalizado pelo contexto do serviço
+		isService, err := daemon.RunService(serviceName, runAgent)
+
+		if err != nil {
+			fmt.Println("Erro ao executar como serviço:", err)
+			os.Exit(exitErro)
 		}
 
-		ps := pubsub.New(
-			[]string{
-				pubsub.AgenteUpdatedEvent,
-				pubsub.PtySessionStartedEvent,
-				pubsub.PtyInputEvent,
-				pubsub.ImplantacaoCreatedEvent,
-			},
-		)
+		if !isService {
+			os.Exit(runAgent(cmd.Context()))
+		}
+	},
+}
+
+// isRevoked informa se err indica que o servidor rejeitou as credenciais.
+func isRevoked(err error) bool {
+	return errors.Is(err, identity.ErrRevoked)
+}
+
+// runAgent executa o agente até ele ser finalizado, retornando o código de
+// saída do processo.
+func runAgent(parent context.Context) int {
+	fmt.Print("\033[H\033[2J")
 
-		ptyManager := pty.NewPtyManager(ps)
+	for _, line := range []string{
+		"",
+		"██╗   ██╗██████╗ ██████╗ ███████╗██████╗ ██╗      ██████╗ ██╗   ██╗",
+		"██║   ██║██╔══██╗██╔══██╗██╔════╝██╔══██╗██║     ██╔═══██╗╚██╗ ██╔╝",
+		"██║   ██║██████╔╝██║  ██║█████╗  ██████╔╝██║     ██║   ██║ ╚████╔╝ ",
+		"╚██╗ ██╔╝██╔══██╗██║  ██║██╔══╝  ██╔═══╝ ██║     ██║   ██║  ╚██╔╝  ",
+		" ╚████╔╝ ██║  ██║██████╔╝███████╗██║     ███████╗╚██████╔╝   ██║   ",
+		"  ╚═══╝  ╚═╝  ╚═╝╚═════╝ ╚══════╝╚═╝     ╚══════╝ ╚═════╝    ╚═╝   ",
+		"",
+	} {
+		fmt.Println(foregroundStyle.Render(line))
+		time.Sleep(200 * time.Millisecond)
+	}
 
-		ps.Subscribe(
+	cfg, err := config.Load()
+
+	if err != nil {
+		fmt.Println("Erro ao carregar configuração:", err)
+		return exitErro
+	}
+
+	if credential.Revoked(cfg.DataDir) {
+		fmt.Println(revokedMessage)
+		return exitRevogado
+	}
+
+	err = api.Configure(cfg.Server)
+
+	if err != nil {
+		fmt.Println("Erro ao configurar servidor:", err)
+		return exitErro
+	}
+
+	err = proxy.Configure(cfg.Proxy)
+
+	if err != nil {
+		fmt.Println("Erro ao configurar proxy:", err)
+		return exitErro
+	}
+
+	store, err := secret.Open(cfg.Secret, cfg.DataDir)
+
+	if err != nil {
+		fmt.Println("Erro ao abrir armazenamento de segredos:", err)
+		return exitErro
+	}
+
+	secret.Use(store)
+
+	ps := pubsub.New(
+		[]string{
+			pubsub.AgenteUpdatedEvent,
+			pubsub.AgenteRotateCredentialsEvent,
+			pubsub.AgenteUpdateEvent,
 			pubsub.PtySessionStartedEvent,
-			ptyManager.HandleSessionStarted(cmd.Context()),
-		)
-		ps.Subscribe(
 			pubsub.PtyInputEvent,
-			ptyManager.HandleInput(),
-		)
-		ps.Subscribe(
+			pubsub.PtySessionCloseEvent,
 			pubsub.ImplantacaoCreatedEvent,
-			pubsub.HandleImplantacaoCreated(),
-		)
+			pubsub.ExecRequestEvent,
+			pubsub.FileGetEvent,
+			pubsub.FilePutEvent,
+			pubsub.FileListEvent,
+			pubsub.FileAckEvent,
+			pubsub.TunnelOpenEvent,
+			pubsub.ServicoActionEvent,
+			pubsub.TunnelDataEvent,
+			pubsub.TunnelAckEvent,
+			pubsub.TunnelCloseEvent,
+			pubsub.RpcRequestEvent,
+			pubsub.RpcResponseEvent,
+			pubsub.RpcCancelEvent,
+		},
+	)
+
+	outbox, err := pubsub.NewOutbox(
+		filepath.Join(cfg.DataDir, "outbox.jsonl"),
+		cfg.Outbox.MaxSize,
+	)
+
+	if err != nil {
+		fmt.Println("Erro ao abrir outbox:", err)
+		return exitErro
+	}
+
+	ps.SetUrl(api.PubSubUrl())
+	ps.SetOutbox(outbox)
+	ps.SetKeepAlive(pubsub.KeepAlive{
+		PingInterval: time.Duration(cfg.Connection.PingInterval) * time.Second,
+		PongTimeout:  time.Duration(cfg.Connection.PongTimeout) * time.Second,
+	})
 
-		tries := 0
+	journal, err := pubsub.NewImplantacaoJournal(
+		filepath.Join(cfg.DataDir, "implantacoes.json"),
+	)
 
-		for {
-			err := ps.Connect()
+	if err != nil {
+		fmt.Println("Erro ao abrir histórico de implantações:", err)
+		return exitErro
+	}
+
+	rpc := pubsub.NewRpc(ps)
+	ptyManager := pty.NewPtyManager(ps, cfg.Terminal)
+	executor := command.NewExecutor(ps, command.NewPolicy(cfg.Exec))
+	transferManager := transfer.NewManager(ps, cfg.Transfer)
+	tunnelManager := tunnel.NewManager(ps, cfg.Tunnel)
+	rotator := credential.NewRotator(ps, cfg.Credentials)
+	reporter := inventory.NewReporter(ps, journal, cfg.Inventory)
+	collector := metrics.NewCollector(ps, cfg.Metrics)
+	watcher := service.NewWatcher(ps, journal, cfg.Services)
+	updater := update.NewUpdater(ps, cfg.Update, cfg.DataDir)
+
+	// Entrada ou dados descartados corromperiam a sessão ou o stream, que
+	// são encerrados com erro
+	ps.SetDispatchPolicy(pubsub.PtyInputEvent, pubsub.DispatchPolicy{
+		Mode:   pubsub.DispatchOrdered,
+		Key:    pubsub.KeyField("idAgente"),
+		OnFull: pubsub.DroppedTyped(ptyManager.HandleInputOverflow),
+	})
+	ps.SetDispatchPolicy(pubsub.PtySessionCloseEvent, pubsub.DispatchPolicy{
+		Mode: pubsub.DispatchOrdered,
+		Key:  pubsub.KeyField("idAgente"),
+	})
+	ps.SetDispatchPolicy(pubsub.ImplantacaoCreatedEvent, pubsub.DispatchPolicy{
+		Mode: pubsub.DispatchOrdered,
+	})
+	ps.SetDispatchPolicy(pubsub.AgenteUpdateEvent, pubsub.DispatchPolicy{
+		Mode: pubsub.DispatchOrdered,
+	})
+	ps.SetDispatchPolicy(pubsub.ExecRequestEvent, pubsub.DispatchPolicy{
+		Mode:    pubsub.DispatchConcurrent,
+		Workers: 4,
+	})
+	ps.SetDispatchPolicy(pubsub.FilePutEvent, pubsub.DispatchPolicy{
+		Mode: pubsub.DispatchOrdered,
+		Key:  pubsub.KeyField("id"),
+	})
+	ps.SetDispatchPolicy(pubsub.TunnelDataEvent, pubsub.DispatchPolicy{
+		Mode:   pubsub.DispatchOrdered,
+		Key:    pubsub.KeyField("streamId"),
+		OnFull: pubsub.DroppedTyped(tunnelManager.HandleDataOverflow),
+	})
+	ps.SetDispatchPolicy(pubsub.ServicoActionEvent, pubsub.DispatchPolicy{
+		Mode: pubsub.DispatchOrdered,
+		Key:  pubsub.KeyField("nome"),
+	})
+
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.PtySessionStartedEvent,
+		ptyManager.HandleSessionStarted,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.PtyInputEvent,
+		ptyManager.HandleInput,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.PtySessionCloseEvent,
+		ptyManager.HandleSessionClose,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.ImplantacaoCreatedEvent,
+		pubsub.HandleImplantacaoCreated(ps, journal),
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.ExecRequestEvent,
+		executor.HandleRequest,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.FileGetEvent,
+		transferManager.HandleGet,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.FilePutEvent,
+		transferManager.HandlePut,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.FileListEvent,
+		transferManager.HandleList,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.FileAckEvent,
+		transferManager.HandleAck,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.TunnelOpenEvent,
+		tunnelManager.HandleOpen,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.TunnelDataEvent,
+		tunnelManager.HandleData,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.TunnelAckEvent,
+		tunnelManager.HandleAck,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.TunnelCloseEvent,
+		tunnelManager.HandleClose,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.ServicoActionEvent,
+		watcher.HandleAction,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.RpcRequestEvent,
+		rpc.HandleRequest,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.RpcResponseEvent,
+		rpc.HandleResponse,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.RpcCancelEvent,
+		rpc.HandleCancel,
+	)
+
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.AgenteRotateCredentialsEvent,
+		rotator.HandleRotate,
+	)
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.AgenteUpdateEvent,
+		updater.HandleUpdate,
+	)
+
+	pubsub.SubscribeTyped(
+		ps,
+		pubsub.AgenteUpdatedEvent,
+		func(ctx context.Context, payload pubsub.AgenteUpdatedPayload) {
+			fmt.Println("Situação do agente:", payload.Situacao)
+		},
+	)
+
+	// Cancelado com errShutdown ao receber SIGINT ou SIGTERM, ou junto com
+	// parent (ex: parada do serviço no Windows), encerrando os handlers e as
+	// sessões derivadas dele
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	// Fechado quando o servidor rejeita as credenciais, ao conectar ou com
+	// a conexão aberta (ex: na rotação ou na sincronização)
+	revoked := make(chan struct{})
+	var revokeOnce sync.Once
+
+	revoke := func() {
+		revokeOnce.Do(func() {
+			err := credential.MarkRevoked(cfg.DataDir)
 
 			if err != nil {
-				fmt.Println("Erro ao conectar ao serviço de pubsub:", err)
+				fmt.Println("Erro ao registrar agente revogado:", err)
+			}
+
+			close(revoked)
+		})
+	}
+
+	exitCode := func() int {
+		select {
+		case <-revoked:
+			return exitRevogado
+		default:
+			return 0
+		}
+	}
+
+	shutdownDone := make(chan struct{})
+
+	go func() {
+		defer close(shutdownDone)
+
+		relaunch := false
+
+		select {
+		case sig := <-signals:
+			fmt.Println("Sinal recebido, finalizando o agente:", sig)
+		case <-updater.Restart():
+			relaunch = true
+		case <-revoked:
+			fmt.Println(revokedMessage)
+		case <-ctx.Done():
+			if parent.Err() == nil {
 				return
 			}
 
-			if err == nil {
-				// A conexão foi bem-sucedida mas ocorreu algo inesperado (ex: falha do servidor)
-				tries = 0
+			fmt.Println("Finalizando o agente:", context.Cause(parent))
+		}
+
+		go func() {
+			<-signals
+			fmt.Println("Encerramento forçado")
+			os.Exit(1)
+		}()
+
+		cancel(errShutdown)
+
+		grace := time.Duration(cfg.Connection.ShutdownGrace) * time.Second
+		shutdown(ps, ptyManager, tunnelManager, grace)
+
+		if relaunch {
+			err := updater.Relaunch()
+
+			if err != nil {
+				fmt.Println("Erro ao reiniciar o agente:", err)
 			}
+		}
+	}()
 
-			tries++
+	defer func() {
+		cancel(nil)
+		<-shutdownDone
+	}()
 
-			if tries >= 5 {
-				fmt.Println("Não foi possível conectar ao serviço de pubsub após várias tentativas:", err)
-				return
+	ps.OnConnect(func(connCtx context.Context) {
+		rotator.Confirm()
+
+		err := sincronizar(ctx, ps, rpc, os.Stdout)
+
+		if isRevoked(err) {
+			revoke()
+		}
+	})
+
+	ps.OnConnect(reporter.OnConnect)
+	ps.OnConnect(collector.OnConnect)
+	ps.OnConnect(watcher.OnConnect)
+	ps.OnConnect(updater.OnConnect)
+
+	ps.OnDisconnect(tunnelManager.OnDisconnect)
+
+	// Depois do tratamento de sinais, que também atende os pedidos de
+	// reinício da atualização
+	updater.Startup(ctx)
+
+	rotator.SetRevokedHandler(revoke)
+
+	go rotator.Run(ctx)
+	go reporter.Run(ctx)
+	go collector.Run(ctx)
+	go watcher.Run(ctx)
+
+	tries := 0
+
+	for {
+		err := ps.Connect(ctx)
+
+		if ctx.Err() != nil {
+			return exitCode()
+		}
+
+		if isRevoked(err) {
+			if rotator.Rollback() {
+				fmt.Println("Nova credencial rejeitada pelo servidor, voltando para a anterior")
+				continue
 			}
 
-			time.Sleep(5 * time.Second)
+			// O encerramento é feito pela goroutine de tratamento de sinais
+			revoke()
+			<-ctx.Done()
+
+			return exitRevogado
 		}
-	},
+
+		if errors.Is(err, pubsub.ErrProtocolUnsupported) {
+			fmt.Println("Erro ao conectar ao serviço de pubsub:", err)
+			return exitErro
+		}
+
+		if err != nil {
+			fmt.Println("Erro ao conectar ao serviço de pubsub:", err)
+		} else {
+			// A conexão foi bem-sucedida mas ocorreu algo inesperado (ex: falha do servidor)
+			tries = 0
+		}
+
+		tries++
+
+		if tries >= 5 {
+			fmt.Println("Não foi possível conectar ao serviço de pubsub após várias tentativas:", err)
+			return exitErro
+		}
+
+		select {
+		case <-ctx.Done():
+			return exitCode()
+		case <-time.After(5 * time.Second):
+		}
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(startCmd)
 }
+
+// shutdown finaliza as sessões e aguarda os handlers em andamento antes de
+// esvaziar a fila de envio e fechar a conexão, respeitando o tempo de grace.
+func shutdown(
+	ps *pubsub.PubSub,
+	ptyManager *pty.PtyManager,
+	tunnelManager *tunnel.Manager,
+	grace time.Duration,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	ptyManager.CloseAll(ctx, errShutdown)
+	tunnelManager.CloseAll()
+
+	err := ps.Drain(ctx)
+
+	if err != nil {
+		fmt.Println("Tempo esgotado aguardando os handlers em andamento:", err)
+	}
+
+	err = ps.Flush(ctx)
+
+	if err != nil {
+		fmt.Println("Tempo esgotado esvaziando a fila de envio:", err)
+	}
+
+	err = ps.Close(ctx, errShutdown.Error())
+
+	if err != nil {
+		fmt.Println("Erro ao fechar conexão:", err)
+	}
+}
+
+// sincronizar busca no servidor o que foi perdido enquanto o agente estava
+// desconectado e entrega aos handlers dos eventos correspondentes. Erros
+// são escritos em diagnostics e retornados.
+func sincronizar(ctx context.Context, ps *pubsub.PubSub, rpc *pubsub.Rpc, diagnostics io.Writer) error {
+	if !ps.Protocol().Has(pubsub.CapabilitySincronizar) {
+		return nil
+	}
+
+	resp, err := buscarSincronizacao(ctx, ps, rpc)
+
+	if err != nil {
+		fmt.Fprintln(diagnostics, "Erro ao sincronizar com o servidor:", err)
+		return err
+	}
+
+	if resp.Agente.Situacao != "" {
+		agente, err := json.Marshal(pubsub.AgenteUpdatedPayload{
+			Situacao: resp.Agente.Situacao,
+		})
+
+		if err == nil {
+			ps.Deliver(ctx, pubsub.AgenteUpdatedEvent, string(agente))
+		}
+	}
+
+	for _, implantacao := range resp.Implantacoes {
+		ps.Deliver(ctx, pubsub.ImplantacaoCreatedEvent, string(implantacao))
+	}
+
+	return nil
+}
+
+// buscarSincronizacao usa o RPC pela conexão já aberta quando o servidor
+// oferece o método e recorre à rota HTTP caso contrário.
+func buscarSincronizacao(ctx context.Context, ps *pubsub.PubSub, rpc *pubsub.Rpc) (api.SincronizarResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, sincronizarTimeout)
+	defer cancel()
+
+	if rpc != nil && ps.Protocol().Has(pubsub.CapabilityRpc) {
+		resp, err := pubsub.Call[struct{}, api.SincronizarResponse](ctx, rpc, rpcSincronizar, struct{}{})
+
+		var rpcErr *pubsub.RpcError
+
+		if !errors.As(err, &rpcErr) || rpcErr.Code != pubsub.RpcErrorMethodNotFound {
+			return resp, err
+		}
+	}
+
+	return api.Sincronizar(ctx)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"agent/pkg/update"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var updateHelperOpts update.HelperOptions
+
+// Executado pelo próprio agente no Windows para trocar o executável após
+// ele finalizar
+var updateHelperCmd = &cobra.Command{
+	Use:    "update-helper",
+	Short:  "Substitui o executável do agente durante uma atualização",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := update.RunHelper(updateHelperOpts)
+
+		if err != nil {
+			fmt.Println("Erro ao aplicar atualização:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	flags := updateHelperCmd.Flags()
+
+	flags.Int32Var(&updateHelperOpts.Pid, "pid", 0, "processo do agente a aguardar")
+	flags.StringVar(&updateHelperOpts.Source, "source", "", "binário a ser instalado")
+	flags.StringVar(&updateHelperOpts.Target, "target", "", "executável do agente")
+	flags.StringVar(&updateHelperOpts.Service, "service", "", "serviço iniciado após a troca")
+
+	rootCmd.AddCommand(updateHelperCmd)
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"agent/pkg/version"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Exibe a versão do vrdeploy",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Usado também na verificação do binário baixado pela atualização:
+		// a saída deve conter apenas a versão
+		fmt.Println(version.Version)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
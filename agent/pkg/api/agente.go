@@ -1,6 +1,8 @@
 package api
 
 import (
+	"agent/pkg/proxy"
+	"agent/pkg/system"
 	"bytes"
 	"encoding/json"
 	"net/http"
@@ -9,11 +11,22 @@ import (
 type CadastrarAgenteRequest struct {
 	EnderecoMac        string `json:"enderecoMac"`
 	SistemaOperacional string `json:"sistemaOperacional"`
+	// Chave pública Ed25519 (base64) usada para autenticar o agente
+	ChavePublica string `json:"chavePublica"`
+	// Identificador do PDV, quando informado no cadastro
+	IdPdv string `json:"idPdv,omitempty"`
+	// Token de cadastro pré-aprovado, dispensa a aprovação manual
+	TokenCadastro string `json:"tokenCadastro,omitempty"`
+	// Identificação estável da máquina, conferida a cada conexão
+	Fingerprint system.Fingerprint `json:"fingerprint"`
 }
 
 type CadastrarAgenteResponse struct {
 	ID           int    `json:"id"`
 	ChaveSecreta string `json:"chaveSecreta"`
+	Situacao     string `json:"situacao"`
+	// Indica que o servidor registrou a chave pública enviada no cadastro
+	ChavePublicaRegistrada bool `json:"chavePublicaRegistrada"`
 }
 
 func CadastrarAgente(req CadastrarAgenteRequest) (CadastrarAgenteResponse, error) {
@@ -25,11 +38,11 @@ func CadastrarAgente(req CadastrarAgenteRequest) (CadastrarAgenteResponse, error
 		return resp, err
 	}
 
-	httpClient := http.Client{}
+	httpClient := proxy.Client()
 
 	request, err := http.NewRequest(
 		http.MethodPost,
-		"http://localhost:3000/api/agente",
+		baseUrl+"/api/agente",
 		bytes.NewBuffer(data),
 	)
 
@@ -47,6 +60,12 @@ func CadastrarAgente(req CadastrarAgenteRequest) (CadastrarAgenteResponse, error
 
 	defer response.Body.Close()
 
+	err = checkStatus(response)
+
+	if err != nil {
+		return resp, err
+	}
+
 	err = json.NewDecoder(response.Body).Decode(&resp)
 
 	return resp, err
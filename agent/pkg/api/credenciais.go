@@ -0,0 +1,83 @@
+package api
+
+import (
+	"agent/pkg/identity"
+	"agent/pkg/proxy"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type RotacionarCredenciaisRequest struct {
+	ChavePublica string `json:"chavePublica"`
+}
+
+type RotacionarCredenciaisResponse struct {
+	// Preenchido apenas por servidores sem suporte à chave pública
+	ChaveSecreta string `json:"chaveSecreta"`
+	// Indica que o servidor registrou a nova chave pública
+	ChavePublicaRegistrada bool `json:"chavePublicaRegistrada"`
+}
+
+// RotacionarCredenciais registra uma nova chave pública para o agente,
+// autenticando com a credencial atual. O servidor aceita as duas chaves até
+// a primeira conexão com a nova.
+func RotacionarCredenciais(req RotacionarCredenciaisRequest) (RotacionarCredenciaisResponse, error) {
+	var resp RotacionarCredenciaisResponse
+
+	data, err := json.Marshal(req)
+
+	if err != nil {
+		return resp, err
+	}
+
+	httpClient := proxy.Client()
+
+	request, err := http.NewRequest(
+		http.MethodPost,
+		baseUrl+"/api/agente/credenciais",
+		bytes.NewBuffer(data),
+	)
+
+	if err != nil {
+		return resp, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	err = identity.Authorize(request.Header)
+
+	if err != nil {
+		return resp, err
+	}
+
+	response, err := httpClient.Do(request)
+
+	if err != nil {
+		return resp, err
+	}
+
+	defer response.Body.Close()
+
+	err = checkStatus(response)
+
+	if err != nil {
+		return resp, err
+	}
+
+	err = json.NewDecoder(response.Body).Decode(&resp)
+
+	return resp, err
+}
+
+func checkStatus(response *http.Response) error {
+	switch {
+	case response.StatusCode == http.StatusUnauthorized, response.StatusCode == http.StatusForbidden:
+		return identity.ErrRevoked
+	case response.StatusCode < 200 || response.StatusCode >= 300:
+		return fmt.Errorf("resposta inesperada do servidor: %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var baseUrl = "http://localhost:3000"
+
+// Configure define o endereço do servidor usado pela API.
+func Configure(server string) error {
+	parsed, err := url.Parse(server)
+
+	if err != nil {
+		return fmt.Errorf("endereço do servidor inválido: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("endereço do servidor inválido: %s", server)
+	}
+
+	baseUrl = strings.TrimRight(server, "/")
+
+	return nil
+}
+
+// PubSubUrl retorna o endereço WebSocket do pubsub no servidor configurado.
+func PubSubUrl() string {
+	if rest, ok := strings.CutPrefix(baseUrl, "https://"); ok {
+		return "wss://" + rest + "/pubsub/agente"
+	}
+
+	return "ws://" + strings.TrimPrefix(baseUrl, "http://") + "/pubsub/agente"
+}
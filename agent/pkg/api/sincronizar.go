@@ -0,0 +1,59 @@
+package api
+
+import (
+	"agent/pkg/identity"
+	"agent/pkg/model"
+	"agent/pkg/proxy"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type SincronizarResponse struct {
+	Agente model.Agente `json:"agente"`
+	// Implantações pendentes no mesmo formato do evento implantacao:created
+	Implantacoes []json.RawMessage `json:"implantacoes"`
+}
+
+// Sincronizar busca a situação atual do agente e as implantações que foram
+// criadas enquanto ele estava desconectado.
+func Sincronizar(ctx context.Context) (SincronizarResponse, error) {
+	var resp SincronizarResponse
+
+	httpClient := proxy.Client()
+
+	request, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		baseUrl+"/api/agente/sincronizar",
+		nil,
+	)
+
+	if err != nil {
+		return resp, err
+	}
+
+	err = identity.Authorize(request.Header)
+
+	if err != nil {
+		return resp, err
+	}
+
+	response, err := httpClient.Do(request)
+
+	if err != nil {
+		return resp, err
+	}
+
+	defer response.Body.Close()
+
+	err = checkStatus(response)
+
+	if err != nil {
+		return resp, err
+	}
+
+	err = json.NewDecoder(response.Body).Decode(&resp)
+
+	return resp, err
+}
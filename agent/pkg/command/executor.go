@@ -0,0 +1,149 @@
+package command
+
+import (
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Tempo aguardado pelos pipes de saída após o processo ser finalizado
+const waitDelay = 5 * time.Second
+
+type Executor struct {
+	ps     *pubsub.PubSub
+	policy *Policy
+}
+
+func NewExecutor(ps *pubsub.PubSub, policy *Policy) *Executor {
+	return &Executor{
+		ps:     ps,
+		policy: policy,
+	}
+}
+
+func (e *Executor) HandleRequest(ctx context.Context, payload pubsub.ExecRequestPayload) {
+	result := e.Run(ctx, payload)
+
+	resultData, err := json.Marshal(result)
+
+	if err != nil {
+		fmt.Println("Erro ao serializar resultado do comando:", err)
+		return
+	}
+
+	err = e.ps.Publish(pubsub.ExecResultEvent, string(resultData))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar resultado do comando:", err)
+	}
+}
+
+// Run executa o comando, se permitido pela política, e retorna o resultado.
+func (e *Executor) Run(ctx context.Context, req pubsub.ExecRequestPayload) pubsub.ExecResultPayload {
+	result := pubsub.ExecResultPayload{
+		ID:       req.ID,
+		ExitCode: -1,
+		Streamed: req.Stream,
+	}
+
+	req, err := e.policy.Check(req)
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.policy.Timeout(req.Timeout))
+	defer cancel()
+
+	cmd := buildCommand(ctx, req)
+
+	var seq atomic.Int64
+
+	stdout := &output{limit: e.policy.MaxOutput()}
+	stderr := &output{limit: e.policy.MaxOutput()}
+
+	if req.Stream {
+		stdout.publish = e.streamTo(ctx, req.ID, "stdout", &seq)
+		stderr.publish = e.streamTo(ctx, req.ID, "stderr", &seq)
+	}
+
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	startedAt := time.Now()
+
+	err = cmd.Run()
+
+	result.Duration = time.Since(startedAt).Milliseconds()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Truncated = stdout.Truncated() || stderr.Truncated()
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	var exitErr *exec.ExitError
+
+	if err != nil && !errors.As(err, &exitErr) {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func (e *Executor) streamTo(ctx context.Context, id string, stream string, seq *atomic.Int64) func([]byte) {
+	return func(data []byte) {
+		payload, err := json.Marshal(pubsub.ExecOutputPayload{
+			ID:     id,
+			Seq:    seq.Add(1),
+			Stream: stream,
+			Data:   string(data),
+		})
+
+		if err != nil {
+			return
+		}
+
+		err = e.ps.PublishWait(ctx, pubsub.ExecOutputEvent, string(payload))
+
+		if err != nil {
+			fmt.Println("Erro ao publicar saída do comando:", err)
+		}
+	}
+}
+
+func buildCommand(ctx context.Context, req pubsub.ExecRequestPayload) *exec.Cmd {
+	var cmd *exec.Cmd
+
+	if req.Shell != "" {
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd.exe", "/C", req.Shell)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", "-c", req.Shell)
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, req.Argv[0], req.Argv[1:]...)
+	}
+
+	cmd.Dir = req.Dir
+	cmd.Env = os.Environ()
+	cmd.WaitDelay = waitDelay
+
+	for key, value := range req.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	killProcessTree(cmd)
+
+	return cmd
+}
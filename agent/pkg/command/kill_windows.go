@@ -0,0 +1,15 @@
+//go:build windows
+
+package command
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// killProcessTree finaliza a árvore de processos do comando com taskkill.
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"bytes"
+	"sync"
+)
+
+// output acumula a saída de um stream até o limite configurado, repassando
+// cada trecho para publish quando o streaming está habilitado.
+type output struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	written   int
+	truncated bool
+	publish   func(data []byte)
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(p)
+	chunk := p
+
+	if o.limit > 0 && o.written+len(chunk) > o.limit {
+		chunk = chunk[:max(o.limit-o.written, 0)]
+		o.truncated = true
+	}
+
+	o.written += len(chunk)
+
+	if len(chunk) == 0 {
+		return n, nil
+	}
+
+	if o.publish != nil {
+		o.publish(chunk)
+		return n, nil
+	}
+
+	o.buf.Write(chunk)
+
+	return n, nil
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.buf.String()
+}
+
+func (o *output) Truncated() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.truncated
+}
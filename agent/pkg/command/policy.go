@@ -0,0 +1,138 @@
+package command
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"agent/pkg/system"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrDisabled = errors.New("execução remota desabilitada")
+
+type Policy struct {
+	cfg config.ExecConfig
+}
+
+func NewPolicy(cfg config.ExecConfig) *Policy {
+	return &Policy{cfg: cfg}
+}
+
+// Check valida se o comando pode ser executado de acordo com a configuração
+// e retorna a requisição com o programa resolvido para o caminho absoluto
+// que será executado.
+func (p *Policy) Check(req pubsub.ExecRequestPayload) (pubsub.ExecRequestPayload, error) {
+	if !p.cfg.Enabled {
+		return req, ErrDisabled
+	}
+
+	switch {
+	case req.Shell != "" && len(req.Argv) > 0:
+		return req, fmt.Errorf("informe apenas argv ou shell")
+	case req.Shell != "":
+		if !p.cfg.AllowShell {
+			return req, fmt.Errorf("execução via shell não permitida")
+		}
+	case len(req.Argv) > 0:
+		program, err := p.resolveProgram(req.Argv[0])
+
+		if err != nil {
+			return req, err
+		}
+
+		argv := slices.Clone(req.Argv)
+		argv[0] = program
+		req.Argv = argv
+	default:
+		return req, fmt.Errorf("nenhum comando informado")
+	}
+
+	for key := range req.Env {
+		if !slices.Contains(p.cfg.Env, key) {
+			return req, fmt.Errorf("variável de ambiente não permitida: %s", key)
+		}
+	}
+
+	if req.Dir != "" && !system.PathWithin(p.cfg.Dirs, req.Dir) {
+		return req, fmt.Errorf("diretório não permitido: %s", req.Dir)
+	}
+
+	return req, nil
+}
+
+// resolveProgram valida o programa contra a allowlist e retorna o caminho
+// absoluto dele. Nomes sem separador são procurados no PATH e comparados
+// com os padrões sem separador; caminhos precisam ser absolutos e são
+// comparados apenas com os padrões absolutos, para que um binário com o
+// mesmo nome em outro diretório não seja liberado.
+func (p *Policy) resolveProgram(program string) (string, error) {
+	bare := !strings.ContainsAny(program, `/\`)
+
+	if !bare && !filepath.IsAbs(program) {
+		return "", fmt.Errorf("o programa deve ser um nome ou um caminho absoluto: %s", program)
+	}
+
+	resolved := filepath.Clean(program)
+
+	if bare {
+		path, err := exec.LookPath(program)
+
+		if err != nil {
+			return "", fmt.Errorf("programa não encontrado: %s", program)
+		}
+
+		resolved, err = filepath.Abs(path)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, pattern := range p.cfg.Allow {
+		if pattern == "*" {
+			return resolved, nil
+		}
+
+		if filepath.Base(pattern) == pattern {
+			if !bare {
+				continue
+			}
+
+			if ok, _ := filepath.Match(pattern, program); ok {
+				return resolved, nil
+			}
+
+			continue
+		}
+
+		if ok, _ := filepath.Match(filepath.Clean(pattern), resolved); ok && filepath.IsAbs(pattern) {
+			return resolved, nil
+		}
+	}
+
+	return "", fmt.Errorf("programa não permitido: %s", program)
+}
+
+// Timeout retorna o tempo limite do comando, limitado pela configuração.
+func (p *Policy) Timeout(requested int) time.Duration {
+	timeout := p.cfg.Timeout
+
+	if requested > 0 {
+		timeout = requested
+	}
+
+	if p.cfg.MaxTimeout > 0 && timeout > p.cfg.MaxTimeout {
+		timeout = p.cfg.MaxTimeout
+	}
+
+	return time.Duration(timeout) * time.Second
+}
+
+func (p *Policy) MaxOutput() int {
+	return p.cfg.MaxOutput
+}
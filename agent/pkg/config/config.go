@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	pathEnv  = "VRDEPLOY_CONFIG"
+	dirName  = "vrdeploy"
+	fileName = "config.json"
+)
+
+type Config struct {
+	// Diretório de dados do agente. Padrão: o mesmo do arquivo de configuração
+	DataDir string `json:"dataDir"`
+	// Endereço do servidor do vrdeploy (ex: https://vrdeploy.empresa.com.br)
+	Server      string            `json:"server"`
+	Connection  ConnectionConfig  `json:"connection"`
+	Proxy       ProxyConfig       `json:"proxy"`
+	Secret      SecretConfig      `json:"secret"`
+	Credentials CredentialsConfig `json:"credentials"`
+	Inventory   InventoryConfig   `json:"inventory"`
+	Metrics     MetricsConfig     `json:"metrics"`
+	Services    ServicesConfig    `json:"services"`
+	Outbox      OutboxConfig      `json:"outbox"`
+	Terminal    TerminalConfig    `json:"terminal"`
+	Exec        ExecConfig        `json:"exec"`
+	Transfer    TransferConfig    `json:"transfer"`
+	Tunnel      TunnelConfig      `json:"tunnel"`
+	Update      UpdateConfig      `json:"update"`
+}
+
+func Default() *Config {
+	return &Config{
+		Server: "http://localhost:3000",
+		Connection: ConnectionConfig{
+			PingInterval:  30,
+			PongTimeout:   10,
+			ShutdownGrace: 15,
+		},
+		Secret: SecretConfig{
+			Backend: "auto",
+		},
+		Credentials: CredentialsConfig{
+			RotationInterval: 720,
+		},
+		Inventory: InventoryConfig{
+			Interval: 15,
+			Packages: true,
+		},
+		Metrics: MetricsConfig{
+			SampleInterval:  10,
+			PublishInterval: 60,
+			BufferSize:      1440,
+			CpuThreshold:    95,
+			MemoryThreshold: 95,
+			DiskThreshold:   90,
+		},
+		Services: ServicesConfig{
+			Interval: 15,
+		},
+		Outbox: OutboxConfig{
+			MaxSize: 10 << 20,
+		},
+		Exec: ExecConfig{
+			Timeout:    60,
+			MaxTimeout: 600,
+			MaxOutput:  1 << 20,
+		},
+		Transfer: TransferConfig{
+			ChunkSize:  64 << 10,
+			Window:     256 << 10,
+			AckTimeout: 60,
+		},
+		Tunnel: TunnelConfig{
+			Window:      256 << 10,
+			DialTimeout: 10,
+		},
+		Update: UpdateConfig{
+			HealthTimeout: 180,
+		},
+	}
+}
+
+// Path retorna o caminho do arquivo de configuração, que pode ser
+// sobrescrito pela variável de ambiente VRDEPLOY_CONFIG.
+func Path() (string, error) {
+	if path := os.Getenv(pathEnv); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// UserPath retorna o caminho padrão do arquivo de configuração de um
+// usuário a partir do seu diretório home, como os.UserConfigDir faria na
+// sessão dele.
+func UserPath(home string) string {
+	dir := filepath.Join(home, ".config")
+
+	if runtime.GOOS == "darwin" {
+		dir = filepath.Join(home, "Library", "Application Support")
+	}
+
+	return filepath.Join(dir, dirName, fileName)
+}
+
+// SetPath faz Path, Load e Save usarem o arquivo informado no restante da
+// execução.
+func SetPath(path string) error {
+	return os.Setenv(pathEnv, path)
+}
+
+// Load lê o arquivo de configuração. Caso ele não exista, a configuração
+// padrão é retornada.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		err = json.Unmarshal(data, cfg)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DataDir == "" {
+		cfg.DataDir = filepath.Dir(path)
+	}
+
+	return cfg, nil
+}
+
+// Save grava a configuração no caminho retornado por Path.
+func Save(cfg *Config) error {
+	path, err := Path()
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
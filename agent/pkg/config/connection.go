@@ -0,0 +1,11 @@
+package config
+
+type ConnectionConfig struct {
+	// Intervalo em segundos entre os pings do WebSocket
+	PingInterval int `json:"pingInterval"`
+	// Tempo em segundos aguardado pelo pong antes de reconectar
+	PongTimeout int `json:"pongTimeout"`
+	// Tempo em segundos que o agente aguarda, ao ser finalizado, para
+	// encerrar as sessões e esvaziar a fila de envio
+	ShutdownGrace int `json:"shutdownGrace"`
+}
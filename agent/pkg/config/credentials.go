@@ -0,0 +1,7 @@
+package config
+
+type CredentialsConfig struct {
+	// Intervalo em horas entre as rotações automáticas da chave do agente.
+	// Zero desativa a rotação periódica
+	RotationInterval int `json:"rotationInterval"`
+}
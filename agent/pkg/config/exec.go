@@ -0,0 +1,17 @@
+package config
+
+type ExecConfig struct {
+	Enabled bool `json:"enabled"`
+	// Programas permitidos. Nomes liberam o programa encontrado no PATH e
+	// caminhos absolutos (ou padrões glob) liberam apenas aquele caminho.
+	// "*" libera todos
+	Allow      []string `json:"allow"`
+	AllowShell bool     `json:"allowShell"`
+	Env        []string `json:"env"`
+	Dirs       []string `json:"dirs"`
+	// Timeouts em segundos
+	Timeout    int `json:"timeout"`
+	MaxTimeout int `json:"maxTimeout"`
+	// Limite em bytes da saída de cada stream
+	MaxOutput int `json:"maxOutput"`
+}
@@ -0,0 +1,8 @@
+package config
+
+type InventoryConfig struct {
+	// Intervalo em minutos entre as coletas. Zero envia apenas na conexão
+	Interval int `json:"interval"`
+	// Inclui os pacotes instalados no inventário
+	Packages bool `json:"packages"`
+}
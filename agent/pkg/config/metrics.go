@@ -0,0 +1,14 @@
+package config
+
+type MetricsConfig struct {
+	// Intervalo em segundos entre as amostras. Zero desativa as métricas
+	SampleInterval int `json:"sampleInterval"`
+	// Intervalo em segundos entre as publicações das amostras agregadas
+	PublishInterval int `json:"publishInterval"`
+	// Quantidade máxima de agregados guardados enquanto desconectado
+	BufferSize int `json:"bufferSize"`
+	// Limites em porcentagem que disparam alertas. Zero desativa o alerta
+	CpuThreshold    float64 `json:"cpuThreshold"`
+	MemoryThreshold float64 `json:"memoryThreshold"`
+	DiskThreshold   float64 `json:"diskThreshold"`
+}
@@ -0,0 +1,6 @@
+package config
+
+type OutboxConfig struct {
+	// Tamanho máximo em bytes do arquivo de eventos pendentes
+	MaxSize int64 `json:"maxSize"`
+}
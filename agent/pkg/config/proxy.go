@@ -0,0 +1,12 @@
+package config
+
+type ProxyConfig struct {
+	// URL do proxy (ex: http://proxy.loja:3128). Quando vazia, são usadas as
+	// variáveis de ambiente HTTPS_PROXY, HTTP_PROXY e NO_PROXY
+	Url string `json:"url"`
+	// Credenciais de autenticação básica, caso não estejam na URL
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Hosts, domínios ou CIDRs separados por vírgula que não passam pelo proxy
+	NoProxy string `json:"noProxy"`
+}
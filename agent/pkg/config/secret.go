@@ -0,0 +1,10 @@
+package config
+
+type SecretConfig struct {
+	// Backend usado para guardar os segredos: auto, keyring, encrypted-file
+	// ou file. No modo auto é usado o primeiro disponível, nessa ordem
+	Backend string `json:"backend"`
+	// Caminho do arquivo nos backends baseados em arquivo. Padrão:
+	// secrets.enc ou secrets.json no diretório de dados
+	Path string `json:"path"`
+}
@@ -0,0 +1,16 @@
+package config
+
+type ServicesConfig struct {
+	// Intervalo em segundos entre as verificações
+	Interval int `json:"interval"`
+	// Serviços monitorados além dos declarados no manifest da última
+	// implantação
+	Watch []ServiceWatch `json:"watch"`
+}
+
+type ServiceWatch struct {
+	// process, systemd ou windows
+	Type string `json:"type"`
+	// Nome do processo, da unidade do systemd ou do serviço do Windows
+	Name string `json:"name"`
+}
@@ -0,0 +1,22 @@
+package config
+
+type TerminalConfig struct {
+	// Shell padrão. Quando vazio, usa $SHELL (ou bash) e cmd.exe no Windows
+	Shell string            `json:"shell"`
+	Args  []string          `json:"args"`
+	Login bool              `json:"login"`
+	Env   map[string]string `json:"env"`
+	Dir   string            `json:"dir"`
+	User  string            `json:"user"`
+	// Campos que o servidor pode sobrescrever ao iniciar uma sessão
+	Allow TerminalAllowlist `json:"allow"`
+}
+
+type TerminalAllowlist struct {
+	Shells []string `json:"shells"`
+	Args   bool     `json:"args"`
+	Login  bool     `json:"login"`
+	Env    []string `json:"env"`
+	Dirs   []string `json:"dirs"`
+	Users  []string `json:"users"`
+}
@@ -0,0 +1,13 @@
+package config
+
+type TransferConfig struct {
+	Enabled bool `json:"enabled"`
+	// Diretório raiz: nenhum arquivo fora dele pode ser lido ou escrito
+	Root string `json:"root"`
+	// Tamanho máximo de cada bloco em bytes
+	ChunkSize int `json:"chunkSize"`
+	// Bytes enviados em um download sem confirmação (file:ack) do servidor
+	Window int64 `json:"window"`
+	// Tempo em segundos aguardando um file:ack antes de abortar o download
+	AckTimeout int `json:"ackTimeout"`
+}
@@ -0,0 +1,11 @@
+package config
+
+type TunnelConfig struct {
+	Enabled bool `json:"enabled"`
+	// Destinos permitidos no formato host:porta. "*" vale para qualquer porta
+	Allow []string `json:"allow"`
+	// Bytes que podem estar em trânsito por stream sem confirmação
+	Window int64 `json:"window"`
+	// Tempo limite de conexão em segundos
+	DialTimeout int `json:"dialTimeout"`
+}
@@ -0,0 +1,10 @@
+package config
+
+type UpdateConfig struct {
+	// Chave pública Ed25519, em base64, que assina os binários do agente.
+	// Sem ela as atualizações remotas são recusadas
+	PublicKey string `json:"publicKey"`
+	// Tempo em segundos para a nova versão conectar ao servidor antes de
+	// voltar para a versão anterior
+	HealthTimeout int `json:"healthTimeout"`
+}
@@ -0,0 +1,46 @@
+package credential
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Arquivo que marca o agente como revogado. Enquanto existir, o start
+// finaliza sem conectar ao servidor; o setup o remove ao cadastrar o agente
+// novamente.
+const revokedFile = "revogado"
+
+// MarkRevoked registra que o servidor rejeitou as credenciais do agente.
+func MarkRevoked(dataDir string) error {
+	err := os.MkdirAll(dataDir, 0700)
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(
+		filepath.Join(dataDir, revokedFile),
+		[]byte(time.Now().UTC().Format(time.RFC3339)+"\n"),
+		0600,
+	)
+}
+
+// Revoked informa se o agente foi marcado como revogado.
+func Revoked(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, revokedFile))
+
+	return err == nil
+}
+
+// ClearRevoked remove a marcação de agente revogado.
+func ClearRevoked(dataDir string) error {
+	err := os.Remove(filepath.Join(dataDir, revokedFile))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
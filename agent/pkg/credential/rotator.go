@@ -0,0 +1,172 @@
+package credential
+
+import (
+	"agent/pkg/api"
+	"agent/pkg/config"
+	"agent/pkg/identity"
+	"agent/pkg/pubsub"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Intervalo entre as verificações da rotação periódica
+const checkInterval = time.Hour
+
+// Tempo aguardado pelo fechamento da conexão antes de reconectar com a
+// nova credencial
+const reconnectTimeout = 10 * time.Second
+
+// Rotator troca a chave do agente quando solicitado pelo servidor ou
+// periodicamente, reconectando com a nova credencial.
+type Rotator struct {
+	ps       *pubsub.PubSub
+	interval time.Duration
+	mu       sync.Mutex
+	// Chamado quando o servidor rejeita a credencial atual
+	revoked func()
+}
+
+func NewRotator(ps *pubsub.PubSub, cfg config.CredentialsConfig) *Rotator {
+	return &Rotator{
+		ps:       ps,
+		interval: time.Duration(cfg.RotationInterval) * time.Hour,
+	}
+}
+
+// SetRevokedHandler define a função chamada quando o servidor rejeita a
+// credencial usada para registrar a nova chave. Deve ser chamado antes de
+// Run.
+func (r *Rotator) SetRevokedHandler(handler func()) {
+	r.revoked = handler
+}
+
+func (r *Rotator) HandleRotate(ctx context.Context, payload pubsub.AgenteRotateCredentialsPayload) {
+	fmt.Println("Rotação de credenciais solicitada pelo servidor:", payload.Motivo)
+
+	err := r.Rotate(ctx)
+
+	if err != nil {
+		fmt.Println("Erro ao rotacionar credenciais:", err)
+	}
+}
+
+// Run executa a rotação periódica até o contexto ser cancelado.
+func (r *Rotator) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.due() {
+			err := r.Rotate(ctx)
+
+			if err != nil {
+				fmt.Println("Erro ao rotacionar credenciais:", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// due informa se a chave atual passou do intervalo de rotação. Sem a data
+// da chave (agentes com apenas o token do servidor) a rotação periódica não
+// acontece; a migração fica a cargo do pedido do servidor.
+func (r *Rotator) due() bool {
+	createdAt, err := identity.CreatedAt()
+
+	if err != nil {
+		return false
+	}
+
+	return time.Since(createdAt) >= r.interval
+}
+
+// Rotate registra uma nova chave no servidor e reconecta usando-a.
+func (r *Rotator) Rotate(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if identity.Pending() {
+		return errors.New("rotação anterior aguardando confirmação")
+	}
+
+	err := identity.Rotate(func(publicKey string) (bool, string, error) {
+		resp, err := api.RotacionarCredenciais(api.RotacionarCredenciaisRequest{
+			ChavePublica: publicKey,
+		})
+
+		if err != nil {
+			return false, "", err
+		}
+
+		return resp.ChavePublicaRegistrada, resp.ChaveSecreta, nil
+	})
+
+	if errors.Is(err, identity.ErrRevoked) && r.revoked != nil {
+		r.revoked()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("Credenciais rotacionadas, reconectando")
+
+	ctx, cancel := context.WithTimeout(ctx, reconnectTimeout)
+	defer cancel()
+
+	return r.ps.Close(ctx, "rotação de credenciais")
+}
+
+// Confirm descarta a credencial anterior e, com a chave aceita, o token
+// emitido pelo servidor. Deve ser chamado após uma conexão bem-sucedida.
+func (r *Rotator) Confirm() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if identity.Pending() {
+		err := identity.Confirm()
+
+		if err != nil {
+			fmt.Println("Erro ao confirmar rotação de credenciais:", err)
+			return
+		}
+
+		log.Println("Nova credencial aceita pelo servidor")
+	}
+
+	// Agentes cadastrados por versões anteriores guardam os dois
+	err := identity.DeleteLegacyToken()
+
+	if err != nil {
+		fmt.Println("Erro ao remover o token do servidor:", err)
+	}
+}
+
+// Rollback volta para a credencial anterior quando a nova é rejeitada.
+// Retorna false se não havia rotação pendente.
+func (r *Rotator) Rollback() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restored, err := identity.Rollback()
+
+	if err != nil {
+		fmt.Println("Erro ao restaurar credencial anterior:", err)
+		return false
+	}
+
+	return restored
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotInstalled = errors.New("serviço não instalado")
+
+// ExitNoRestart é o código de saída do agente quando ele não deve ser
+// reiniciado pelo sistema de inicialização (ex: credenciais revogadas).
+// Segue o EX_CONFIG de sysexits.h
+const ExitNoRestart = 78
+
+// Spec descreve o serviço do agente a ser instalado.
+type Spec struct {
+	Name        string
+	Description string
+	Executable  string
+	Args        []string
+	// Usuário que executa o agente. Vazio executa como root (ou
+	// LocalSystem no Windows)
+	User string
+	// Variáveis de ambiente do serviço (ex: VRDEPLOY_CONFIG)
+	Env map[string]string
+	// Tempo em segundos aguardado pelo encerramento gracioso antes de
+	// finalizar o agente à força
+	StopTimeout int
+	// Arquivo de log usado pelo OpenRC e pelo SysV
+	LogPath string
+}
+
+type Status struct {
+	Init      string `json:"init"`
+	Installed bool   `json:"installed"`
+	Enabled   bool   `json:"enabled"`
+	Running   bool   `json:"running"`
+	// Arquivo da unidade ou script de inicialização
+	Path string `json:"path,omitempty"`
+}
+
+// InitSystem instala e controla o serviço em um sistema de inicialização.
+type InitSystem interface {
+	Name() string
+	Install(spec Spec) error
+	Uninstall(spec Spec) error
+	Status(spec Spec) (Status, error)
+}
+
+// Get retorna o sistema de inicialização pelo nome ou, com nome vazio, o
+// detectado na máquina.
+func Get(name string) (InitSystem, error) {
+	if name == "" {
+		return Detect()
+	}
+
+	for _, initSystem := range initSystems {
+		if initSystem.Name() == name {
+			return initSystem, nil
+		}
+	}
+
+	return nil, fmt.Errorf("sistema de inicialização não suportado: %s", name)
+}
+
+// Installed indica se o serviço está instalado no sistema de inicialização
+// detectado.
+func Installed(spec Spec) bool {
+	initSystem, err := Detect()
+
+	if err != nil {
+		return false
+	}
+
+	status, err := initSystem.Status(spec)
+
+	return err == nil && status.Installed
+}
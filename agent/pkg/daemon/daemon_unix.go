@@ -0,0 +1,333 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var initSystems = []InitSystem{systemd{}, openrc{}, sysv{}}
+
+// Detect retorna o sistema de inicialização em uso na máquina.
+func Detect() (InitSystem, error) {
+	if isDir("/run/systemd/system") {
+		return systemd{}, nil
+	}
+
+	if isDir("/run/openrc") || fileExists("/sbin/openrc-run") {
+		return openrc{}, nil
+	}
+
+	if isDir("/etc/init.d") {
+		return sysv{}, nil
+	}
+
+	return nil, errors.New("nenhum sistema de inicialização suportado encontrado")
+}
+
+// RunService só tem efeito no Windows, onde o agente precisa responder ao
+// gerenciador de serviços.
+func RunService(name string, run func(ctx context.Context) int) (bool, error) {
+	return false, nil
+}
+
+type systemd struct{}
+
+func (systemd) Name() string {
+	return "systemd"
+}
+
+func (systemd) path(spec Spec) string {
+	return filepath.Join("/etc/systemd/system", spec.Name+".service")
+}
+
+func (s systemd) Install(spec Spec) error {
+	unit, err := RenderSystemd(spec)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(s.path(spec), []byte(unit), 0644)
+
+	if err != nil {
+		return err
+	}
+
+	err = run("systemctl", "daemon-reload")
+
+	if err != nil {
+		return err
+	}
+
+	err = run("systemctl", "enable", spec.Name)
+
+	if err != nil {
+		return err
+	}
+
+	// Restart também inicia o serviço caso esteja parado
+	return run("systemctl", "restart", spec.Name)
+}
+
+func (s systemd) Uninstall(spec Spec) error {
+	if !fileExists(s.path(spec)) {
+		return ErrNotInstalled
+	}
+
+	// Não é um erro crítico: a unidade pode não estar carregada
+	run("systemctl", "disable", "--now", spec.Name)
+
+	err := os.Remove(s.path(spec))
+
+	if err != nil {
+		return err
+	}
+
+	return run("systemctl", "daemon-reload")
+}
+
+func (s systemd) Status(spec Spec) (Status, error) {
+	status := Status{
+		Init:      s.Name(),
+		Path:      s.path(spec),
+		Installed: fileExists(s.path(spec)),
+	}
+
+	if !status.Installed {
+		return status, nil
+	}
+
+	status.Enabled = run("systemctl", "is-enabled", "--quiet", spec.Name) == nil
+	status.Running = run("systemctl", "is-active", "--quiet", spec.Name) == nil
+
+	return status, nil
+}
+
+type openrc struct{}
+
+func (openrc) Name() string {
+	return "openrc"
+}
+
+func (openrc) path(spec Spec) string {
+	return filepath.Join("/etc/init.d", spec.Name)
+}
+
+func (o openrc) Install(spec Spec) error {
+	script, err := RenderOpenRC(spec)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(o.path(spec), []byte(script), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	err = run("rc-update", "add", spec.Name, "default")
+
+	if err != nil {
+		return err
+	}
+
+	return run("rc-service", spec.Name, "restart")
+}
+
+func (o openrc) Uninstall(spec Spec) error {
+	if !fileExists(o.path(spec)) {
+		return ErrNotInstalled
+	}
+
+	run("rc-service", spec.Name, "stop")
+	run("rc-update", "del", spec.Name, "default")
+
+	return os.Remove(o.path(spec))
+}
+
+func (o openrc) Status(spec Spec) (Status, error) {
+	status := Status{
+		Init:      o.Name(),
+		Path:      o.path(spec),
+		Installed: fileExists(o.path(spec)),
+	}
+
+	if !status.Installed {
+		return status, nil
+	}
+
+	status.Enabled = fileExists(filepath.Join("/etc/runlevels/default", spec.Name))
+	status.Running = run("rc-service", spec.Name, "status") == nil
+
+	return status, nil
+}
+
+type sysv struct{}
+
+func (sysv) Name() string {
+	return "sysv"
+}
+
+func (sysv) path(spec Spec) string {
+	return filepath.Join("/etc/init.d", spec.Name)
+}
+
+func (s sysv) Install(spec Spec) error {
+	if spec.User != "" {
+		return errors.New("o SysV não suporta executar o agente com outro usuário")
+	}
+
+	script, err := RenderSysV(spec)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(s.path(spec), []byte(script), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.enable(spec)
+
+	if err != nil {
+		return err
+	}
+
+	return run(s.path(spec), "restart")
+}
+
+func (s sysv) Uninstall(spec Spec) error {
+	if !fileExists(s.path(spec)) {
+		return ErrNotInstalled
+	}
+
+	run(s.path(spec), "stop")
+
+	err := s.disable(spec)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(s.path(spec))
+}
+
+func (s sysv) Status(spec Spec) (Status, error) {
+	status := Status{
+		Init:      s.Name(),
+		Path:      s.path(spec),
+		Installed: fileExists(s.path(spec)),
+	}
+
+	if !status.Installed {
+		return status, nil
+	}
+
+	links, _ := filepath.Glob(filepath.Join("/etc", "rc[2345].d", "S*"+spec.Name))
+
+	status.Enabled = len(links) > 0
+	status.Running = run(s.path(spec), "status") == nil
+
+	return status, nil
+}
+
+// enable usa a ferramenta da distribuição ou, na falta dela, cria os links
+// dos runlevels diretamente.
+func (s sysv) enable(spec Spec) error {
+	if _, err := exec.LookPath("update-rc.d"); err == nil {
+		return run("update-rc.d", spec.Name, "defaults")
+	}
+
+	if _, err := exec.LookPath("chkconfig"); err == nil {
+		return run("chkconfig", "--add", spec.Name)
+	}
+
+	for _, level := range []string{"0", "1", "6"} {
+		err := relink(s.path(spec), filepath.Join("/etc", "rc"+level+".d", "K10"+spec.Name))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, level := range []string{"2", "3", "4", "5"} {
+		err := relink(s.path(spec), filepath.Join("/etc", "rc"+level+".d", "S90"+spec.Name))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s sysv) disable(spec Spec) error {
+	if _, err := exec.LookPath("update-rc.d"); err == nil {
+		return run("update-rc.d", "-f", spec.Name, "remove")
+	}
+
+	if _, err := exec.LookPath("chkconfig"); err == nil {
+		return run("chkconfig", "--del", spec.Name)
+	}
+
+	links, _ := filepath.Glob(filepath.Join("/etc", "rc[0-6].d", "[SK][0-9][0-9]"+spec.Name))
+
+	for _, link := range links {
+		err := os.Remove(link)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func relink(target string, link string) error {
+	err := os.MkdirAll(filepath.Dir(link), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	os.Remove(link)
+
+	return os.Symlink(target, link)
+}
+
+// run executa o comando e inclui a saída na mensagem de erro.
+func run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+
+	return err == nil
+}
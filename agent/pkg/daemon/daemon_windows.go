@@ -0,0 +1,324 @@
+//go:build windows
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var errServiceStopped = errors.New("serviço finalizado pelo gerenciador de serviços")
+
+var initSystems = []InitSystem{windowsService{}}
+
+func Detect() (InitSystem, error) {
+	return windowsService{}, nil
+}
+
+type windowsService struct{}
+
+func (windowsService) Name() string {
+	return "windows"
+}
+
+func (w windowsService) Install(spec Spec) error {
+	if spec.User != "" {
+		return errors.New("o serviço do Windows é executado apenas como LocalSystem")
+	}
+
+	m, err := mgr.Connect()
+
+	if err != nil {
+		return err
+	}
+
+	defer m.Disconnect()
+
+	s, err := m.OpenService(spec.Name)
+
+	if err != nil {
+		s, err = m.CreateService(spec.Name, spec.Executable, mgr.Config{
+			DisplayName: spec.Name,
+		}, spec.Args...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	defer s.Close()
+
+	// Também atualiza o executável e os argumentos em uma reinstalação
+	err = updateService(s, spec)
+
+	if err != nil {
+		return err
+	}
+
+	// Reinicia o agente em qualquer finalização inesperada, inclusive com
+	// código de saída diferente de zero
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+
+	if err != nil {
+		return err
+	}
+
+	err = s.SetRecoveryActionsOnNonCrashFailures(true)
+
+	if err != nil {
+		return err
+	}
+
+	err = setEnvironment(spec)
+
+	if err != nil {
+		return err
+	}
+
+	err = stopService(s, spec.StopTimeout)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Start()
+}
+
+func (w windowsService) Uninstall(spec Spec) error {
+	m, err := mgr.Connect()
+
+	if err != nil {
+		return err
+	}
+
+	defer m.Disconnect()
+
+	s, err := m.OpenService(spec.Name)
+
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return ErrNotInstalled
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	err = stopService(s, spec.StopTimeout)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Delete()
+}
+
+func (w windowsService) Status(spec Spec) (Status, error) {
+	status := Status{Init: w.Name()}
+
+	m, err := mgr.Connect()
+
+	if err != nil {
+		return status, err
+	}
+
+	defer m.Disconnect()
+
+	s, err := m.OpenService(spec.Name)
+
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return status, nil
+	}
+
+	if err != nil {
+		return status, err
+	}
+
+	defer s.Close()
+
+	status.Installed = true
+
+	cfg, err := s.Config()
+
+	if err != nil {
+		return status, err
+	}
+
+	status.Path = cfg.BinaryPathName
+	status.Enabled = cfg.StartType == mgr.StartAutomatic
+
+	query, err := s.Query()
+
+	if err != nil {
+		return status, err
+	}
+
+	status.Running = query.State == svc.Running
+
+	return status, nil
+}
+
+func updateService(s *mgr.Service, spec Spec) error {
+	cfg, err := s.Config()
+
+	if err != nil {
+		return err
+	}
+
+	cfg.BinaryPathName = windows.ComposeCommandLine(append([]string{spec.Executable}, spec.Args...))
+	cfg.Description = spec.Description
+	cfg.StartType = mgr.StartAutomatic
+	cfg.DelayedAutoStart = true
+
+	return s.UpdateConfig(cfg)
+}
+
+// setEnvironment grava as variáveis de ambiente do serviço no valor
+// Environment da sua chave no registro.
+func setEnvironment(spec Spec) error {
+	key, err := registry.OpenKey(
+		registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Services\`+spec.Name,
+		registry.SET_VALUE,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer key.Close()
+
+	var env []string
+
+	for name, value := range spec.Env {
+		env = append(env, name+"="+value)
+	}
+
+	slices.Sort(env)
+
+	return key.SetStringsValue("Environment", env)
+}
+
+// stopService para o serviço, se estiver em execução, aguardando o
+// encerramento gracioso do agente.
+func stopService(s *mgr.Service, timeout int) error {
+	query, err := s.Query()
+
+	if err != nil {
+		return err
+	}
+
+	if query.State == svc.Stopped {
+		return nil
+	}
+
+	if query.State != svc.StopPending {
+		_, err = s.Control(svc.Stop)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+
+	for time.Now().Before(deadline) {
+		query, err = s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		if query.State == svc.Stopped {
+			return nil
+		}
+
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	return errors.New("tempo esgotado aguardando o serviço parar")
+}
+
+// RunService executa o agente sob o gerenciador de serviços quando o
+// processo foi iniciado por ele. Retorna false quando executado fora do
+// gerenciador (ex: pelo terminal).
+func RunService(name string, run func(ctx context.Context) int) (bool, error) {
+	isService, err := svc.IsWindowsService()
+
+	if err != nil {
+		return false, err
+	}
+
+	if !isService {
+		return false, nil
+	}
+
+	return true, svc.Run(name, &handler{run: run})
+}
+
+type handler struct {
+	run func(ctx context.Context) int
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	done := make(chan struct{})
+
+	var code int
+
+	go func() {
+		defer close(done)
+
+		code = h.run(ctx)
+	}()
+
+	changes <- svc.Status{
+		State:   svc.Running,
+		Accepts: svc.AcceptStop | svc.AcceptShutdown,
+	}
+
+	for {
+		select {
+		case <-done:
+			changes <- svc.Status{State: svc.StopPending}
+
+			// Parada sem erro, para que as ações de recuperação não
+			// reiniciem o agente
+			if code == ExitNoRestart {
+				return false, 0
+			}
+
+			// O agente finalizou sem o pedido do gerenciador: o código de
+			// saída aciona as ações de recuperação
+			return true, 1
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+
+				cancel(errServiceStopped)
+				<-done
+
+				return false, 0
+			}
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+var templateFuncs = template.FuncMap{
+	"exitNoRestart": func() int {
+		return ExitNoRestart
+	},
+	"systemdQuote": systemdQuote,
+	"shellQuote":   shellQuote,
+	"join":         strings.Join,
+	"shellArgs": func(args []string) string {
+		quoted := make([]string, len(args))
+
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+
+		return strings.Join(quoted, " ")
+	},
+}
+
+// Unidade de sistema: inicia no boot sem depender de login e reinicia o
+// agente sempre que ele finalizar, exceto com ExitNoRestart.
+//
+// O hardening se limita ao que não afeta as implantações e os comandos
+// remotos, que instalam pacotes e alteram o sistema como o usuário do
+// serviço:
+//   - ProtectSystem e ProtectHome ficam de fora porque as implantações
+//     escrevem em /usr, /etc e /opt, a atualização substitui o executável e
+//     a configuração criada pelo setup fica na pasta do usuário;
+//   - NoNewPrivileges só é aplicado com User: executando como root, ele
+//     impediria os scripts de usar binários setuid como sudo e su.
+var systemdTemplate = template.Must(template.New("systemd").Funcs(templateFuncs).Parse(`[Unit]
+Description={{ .Description }}
+Wants=network-online.target
+After=network-online.target
+StartLimitIntervalSec=300
+StartLimitBurst=10
+
+[Service]
+Type=simple
+ExecStart={{ systemdQuote .Executable }}{{ range .Args }} {{ systemdQuote . }}{{ end }}
+{{- range $key, $value := .Env }}
+Environment={{ systemdQuote (print $key "=" $value) }}
+{{- end }}
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+Restart=always
+RestartSec=5
+RestartPreventExitStatus={{ exitNoRestart }}
+KillMode=mixed
+TimeoutStopSec={{ .StopTimeout }}
+{{ if .User }}
+NoNewPrivileges=yes
+{{- end }}
+PrivateTmp=yes
+ProtectClock=yes
+ProtectControlGroups=yes
+ProtectKernelLogs=yes
+ProtectKernelModules=yes
+ProtectKernelTunables=yes
+LockPersonality=yes
+RestrictRealtime=yes
+SystemCallArchitectures=native
+UMask=0027
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// Script do OpenRC usando o supervise-daemon para reiniciar o agente. O
+// supervise-daemon não tem um equivalente a RestartPreventExitStatus; o
+// limite de reinícios, o mesmo da unidade do systemd, interrompe o agente
+// que finaliza com ExitNoRestart logo ao iniciar.
+var openrcTemplate = template.Must(template.New("openrc").Funcs(templateFuncs).Parse(`#!/sbin/openrc-run
+
+name={{ shellQuote .Name }}
+description={{ shellQuote .Description }}
+
+supervisor="supervise-daemon"
+command={{ shellQuote .Executable }}
+command_args="{{ join .Args " " }}"
+{{- if .User }}
+command_user={{ shellQuote .User }}
+{{- end }}
+pidfile="/run/${RC_SVCNAME}.pid"
+output_log={{ shellQuote .LogPath }}
+error_log={{ shellQuote .LogPath }}
+respawn_delay=5
+respawn_max=10
+respawn_period=300
+retry="TERM/{{ .StopTimeout }}/KILL/5"
+{{ range $key, $value := .Env }}
+export {{ $key }}={{ shellQuote $value }}
+{{- end }}
+
+depend() {
+	need net
+	after firewall
+}
+`))
+
+// Script SysV portável. Sem um supervisor no init, o agente é executado em
+// um laço que o reinicia até ele finalizar com ExitNoRestart, em um grupo
+// de processos próprio para que o stop alcance o laço e o agente.
+var sysvTemplate = template.Must(template.New("sysv").Funcs(templateFuncs).Parse(`#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          {{ .Name }}
+# Required-Start:    $network $remote_fs $syslog
+# Required-Stop:     $network $remote_fs $syslog
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: {{ .Description }}
+### END INIT INFO
+
+NAME={{ shellQuote .Name }}
+DAEMON={{ shellQuote .Executable }}
+PIDFILE="/var/run/$NAME.pid"
+LOGFILE={{ shellQuote .LogPath }}
+STOP_TIMEOUT={{ .StopTimeout }}
+{{ range $key, $value := .Env }}
+export {{ $key }}={{ shellQuote $value }}
+{{- end }}
+
+is_running() {
+	[ -f "$PIDFILE" ] && kill -0 -"$(cat "$PIDFILE")" 2>/dev/null
+}
+
+start() {
+	if is_running; then
+		echo "$NAME já está em execução"
+		return 0
+	fi
+
+	echo "Iniciando $NAME"
+
+	setsid sh -c 'echo $$ > "$1"; shift; while true; do "$@"; [ $? -eq {{ exitNoRestart }} ] && break; sleep 5; done' \
+		"$NAME" "$PIDFILE" "$DAEMON" {{ shellArgs .Args }} >> "$LOGFILE" 2>&1 < /dev/null &
+}
+
+stop() {
+	if ! is_running; then
+		echo "$NAME não está em execução"
+		rm -f "$PIDFILE"
+		return 0
+	fi
+
+	echo "Finalizando $NAME"
+
+	pgid="$(cat "$PIDFILE")"
+	kill -TERM -"$pgid" 2>/dev/null
+
+	waited=0
+
+	while kill -0 -"$pgid" 2>/dev/null; do
+		if [ "$waited" -ge "$STOP_TIMEOUT" ]; then
+			kill -KILL -"$pgid" 2>/dev/null
+			break
+		fi
+
+		sleep 1
+		waited=$((waited + 1))
+	done
+
+	rm -f "$PIDFILE"
+}
+
+case "$1" in
+	start)
+		start
+		;;
+	stop)
+		stop
+		;;
+	restart)
+		stop
+		start
+		;;
+	status)
+		if is_running; then
+			echo "$NAME em execução"
+			exit 0
+		fi
+
+		echo "$NAME parado"
+		exit 3
+		;;
+	*)
+		echo "Uso: $0 {start|stop|restart|status}"
+		exit 2
+		;;
+esac
+`))
+
+func RenderSystemd(spec Spec) (string, error) {
+	return render(systemdTemplate, spec)
+}
+
+func RenderOpenRC(spec Spec) (string, error) {
+	return render(openrcTemplate, spec)
+}
+
+func RenderSysV(spec Spec) (string, error) {
+	return render(sysvTemplate, spec)
+}
+
+func render(tmpl *template.Template, spec Spec) (string, error) {
+	var buf bytes.Buffer
+
+	err := tmpl.Execute(&buf, spec)
+
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// systemdQuote coloca o valor entre aspas duplas quando necessário, no
+// formato aceito por ExecStart e Environment.
+func systemdQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'\\$%;") {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// shellQuote coloca o valor entre aspas simples para uso em scripts sh.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
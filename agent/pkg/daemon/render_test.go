@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "atualiza os arquivos golden")
+
+func testSpec() Spec {
+	return Spec{
+		Name:        "vrdeploy",
+		Description: "Agente do vrdeploy",
+		Executable:  "/usr/local/bin/vrdeploy",
+		Args:        []string{"start"},
+		Env: map[string]string{
+			"VRDEPLOY_CONFIG": "/etc/vrdeploy/config.json",
+		},
+		StopTimeout: 20,
+		LogPath:     "/var/log/vrdeploy.log",
+	}
+}
+
+// Caminhos com espaços e aspas e execução com outro usuário
+func testSpecCustom() Spec {
+	spec := testSpec()
+	spec.Executable = "/opt/vr deploy/bin/vrdeploy"
+	spec.User = "vrdeploy"
+	spec.Env = map[string]string{
+		"VRDEPLOY_CONFIG": "/home/pdv/it's config/config.json",
+		"HTTPS_PROXY":     "http://proxy:3128",
+	}
+
+	return spec
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		golden string
+		render func(Spec) (string, error)
+		spec   Spec
+	}{
+		{"systemd.service", RenderSystemd, testSpec()},
+		{"systemd-custom.service", RenderSystemd, testSpecCustom()},
+		{"openrc.init", RenderOpenRC, testSpec()},
+		{"openrc-custom.init", RenderOpenRC, testSpecCustom()},
+		{"sysv.init", RenderSysV, testSpec()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := tt.render(tt.spec)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", tt.golden+".golden")
+
+			if *update {
+				err = os.WriteFile(path, []byte(got), 0644)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("%s difere do golden:\n--- obtido\n%s\n--- esperado\n%s", tt.golden, got, want)
+			}
+		})
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/vrdeploy":  "/usr/bin/vrdeploy",
+		"/opt/vr deploy/bin": `"/opt/vr deploy/bin"`,
+		`a"b\c`:              `"a\"b\\c"`,
+		"100%$HOME":          `"100%%$$HOME"`,
+		"":                   `""`,
+	}
+
+	for value, want := range tests {
+		if got := systemdQuote(value); got != want {
+			t.Errorf("systemdQuote(%q) = %s, esperado %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package identity
+
+import (
+	"agent/pkg/secret"
+	"agent/pkg/system"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrRevoked indica que o servidor rejeitou as credenciais do agente, seja
+// na conexão do pubsub ou nas rotas HTTP.
+var ErrRevoked = errors.New("credenciais do agente rejeitadas pelo servidor")
+
+// Validade do token assinado enviado em cada conexão
+const tokenTTL = time.Minute
+
+const audience = "vrdeploy"
+
+// Generate cria um novo par de chaves Ed25519 apenas em memória. A chave
+// privada só deve ser guardada com Save depois que o servidor registrar a
+// chave pública, para não substituir uma credencial que ainda funciona.
+func Generate() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// Save guarda a chave privada no armazenamento de segredos, substituindo a
+// anterior.
+func Save(priv ed25519.PrivateKey) error {
+	store := secret.Current()
+
+	err := store.Set(
+		secret.PrivateKeyKey,
+		base64.StdEncoding.EncodeToString(priv.Seed()),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return store.Set(secret.PrivateKeyCreatedAtKey, time.Now().UTC().Format(time.RFC3339))
+}
+
+// CreatedAt retorna quando a chave atual foi gerada.
+func CreatedAt() (time.Time, error) {
+	value, err := secret.Current().Get(secret.PrivateKeyCreatedAtKey)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func load() (ed25519.PrivateKey, error) {
+	value, err := secret.Current().Get(secret.PrivateKeyKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(value)
+
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("chave privada inválida")
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// EncodePublicKey retorna a chave pública no formato enviado ao servidor.
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// Fingerprint identifica a chave pública do agente.
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+
+	return hex.EncodeToString(sum[:])
+}
+
+type claims struct {
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	// Fingerprint da máquina, conferido pelo servidor com o do cadastro
+	Machine string `json:"fp,omitempty"`
+}
+
+// Token gera um JWT EdDSA de curta duração que prova a posse da chave
+// privada. O servidor identifica o agente pelo fingerprint em "sub" e "kid".
+func Token() (string, error) {
+	priv, err := load()
+
+	if err != nil {
+		return "", err
+	}
+
+	return sign(priv, time.Now())
+}
+
+func sign(priv ed25519.PrivateKey, now time.Time) (string, error) {
+	fingerprint := Fingerprint(priv.Public().(ed25519.PublicKey))
+
+	machine, err := system.MachineFingerprint()
+
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+
+	_, err = rand.Read(jti)
+
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "EdDSA",
+		"typ": "JWT",
+		"kid": fingerprint,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims{
+		Subject:   fingerprint,
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(tokenTTL).Unix(),
+		ID:        hex.EncodeToString(jti),
+		Machine:   machine.ID,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	signature := ed25519.Sign(priv, []byte(unsigned))
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Authorize adiciona as credenciais do agente à requisição. Agentes com
+// chave privada enviam apenas o token assinado; o token emitido pelo
+// servidor só é enviado por agentes cujo servidor não registrou a chave
+// pública. O fingerprint da máquina também é enviado para ser conferido com
+// o do cadastro.
+func Authorize(header http.Header) error {
+	machine, err := system.MachineFingerprint()
+
+	if err != nil {
+		return err
+	}
+
+	header.Set("X-Agente-Fingerprint", machine.ID)
+
+	token, err := Token()
+
+	if err == nil {
+		header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	if !errors.Is(err, secret.ErrNotFound) {
+		return fmt.Errorf("erro ao assinar token: %w", err)
+	}
+
+	legacy, err := secret.Get()
+
+	if err != nil {
+		return err
+	}
+
+	header.Set("X-Agente-Token", legacy)
+
+	return nil
+}
+
+// DeleteLegacyToken remove o token emitido pelo servidor quando o agente
+// possui uma chave privada, que passa a ser a única credencial enviada.
+func DeleteLegacyToken() error {
+	_, err := load()
+
+	if errors.Is(err, secret.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return secret.Delete()
+}
+
+// DeleteKey remove a chave privada do agente, mantendo o token emitido pelo
+// servidor.
+func DeleteKey() error {
+	store := secret.Current()
+
+	for _, key := range []string{
+		secret.PreviousPrivateKeyKey,
+		secret.PrivateKeyCreatedAtKey,
+		secret.PrivateKeyKey,
+	} {
+		err := store.Delete(key)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete remove todas as credenciais do agente.
+func Delete() error {
+	err := DeleteKey()
+
+	if err != nil {
+		return err
+	}
+
+	err = secret.Current().Delete(secret.PreviousTokenKey)
+
+	if err != nil {
+		return err
+	}
+
+	return secret.Delete()
+}
@@ -0,0 +1,180 @@
+package identity
+
+import (
+	"agent/pkg/secret"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"log"
+	"time"
+)
+
+// Rotate gera uma nova chave e registra a chave pública no servidor através
+// de register (autenticado com a credencial atual). Se o servidor registrar
+// a chave, ela passa a ser a única credencial do agente; caso contrário,
+// o agente passa a usar o token retornado por register. A chave e o token
+// anteriores são mantidos até Confirm, permitindo voltar a eles com
+// Rollback caso o servidor rejeite a nova credencial.
+func Rotate(register func(publicKey string) (bool, string, error)) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		return err
+	}
+
+	registered, token, err := register(EncodePublicKey(pub))
+
+	if err != nil {
+		return err
+	}
+
+	if !registered && token == "" {
+		return errors.New("servidor não registrou a nova chave nem emitiu um token")
+	}
+
+	store := secret.Current()
+
+	// Agentes que ainda usam o token do servidor não possuem chave anterior;
+	// o valor vazio indica que o rollback deve remover a chave
+	previous, err := store.Get(secret.PrivateKeyKey)
+
+	if err != nil && !errors.Is(err, secret.ErrNotFound) {
+		return err
+	}
+
+	previousToken, err := store.Get(secret.TokenKey)
+
+	if err != nil && !errors.Is(err, secret.ErrNotFound) {
+		return err
+	}
+
+	err = store.Set(secret.PreviousTokenKey, previousToken)
+
+	if err != nil {
+		return err
+	}
+
+	// Gravada por último: Pending depende apenas dela
+	err = store.Set(secret.PreviousPrivateKeyKey, previous)
+
+	if err != nil {
+		return err
+	}
+
+	if registered {
+		err = Save(priv)
+
+		if err == nil {
+			err = store.Delete(secret.TokenKey)
+		}
+	} else {
+		// Servidor sem suporte à chave pública: apenas o token é trocado
+		err = store.Set(secret.TokenKey, token)
+
+		if err == nil {
+			err = store.Delete(secret.PrivateKeyKey)
+		}
+	}
+
+	if err != nil {
+		if restoreErr := restore(previous, previousToken); restoreErr != nil {
+			log.Println("Erro ao restaurar a credencial anterior:", restoreErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Pending informa se há uma rotação aguardando confirmação.
+func Pending() bool {
+	_, err := secret.Current().Get(secret.PreviousPrivateKeyKey)
+
+	return err == nil
+}
+
+// Confirm descarta a credencial anterior após a nova ser aceita.
+func Confirm() error {
+	store := secret.Current()
+
+	err := store.Delete(secret.PreviousTokenKey)
+
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(secret.PreviousPrivateKeyKey)
+}
+
+// Rollback volta para a credencial anterior à rotação pendente. Retorna
+// false se não houver rotação pendente.
+func Rollback() (bool, error) {
+	store := secret.Current()
+
+	previous, err := store.Get(secret.PreviousPrivateKeyKey)
+
+	if errors.Is(err, secret.ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	previousToken, err := store.Get(secret.PreviousTokenKey)
+
+	if err != nil && !errors.Is(err, secret.ErrNotFound) {
+		return false, err
+	}
+
+	err = restore(previous, previousToken)
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// restore grava a chave e o token anteriores. Valores vazios indicam que a
+// credencial não existia antes da rotação.
+func restore(previous string, previousToken string) error {
+	store := secret.Current()
+
+	var err error
+
+	if previous == "" {
+		err = store.Delete(secret.PrivateKeyKey)
+	} else {
+		err = store.Set(secret.PrivateKeyKey, previous)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if previousToken == "" {
+		err = store.Delete(secret.TokenKey)
+	} else {
+		err = store.Set(secret.TokenKey, previousToken)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// A data da chave anterior não é conhecida; o intervalo da rotação
+	// periódica recomeça a contar a partir daqui
+	if previous == "" {
+		err = store.Delete(secret.PrivateKeyCreatedAtKey)
+	} else {
+		err = store.Set(secret.PrivateKeyCreatedAtKey, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return Confirm()
+}
@@ -0,0 +1,252 @@
+package inventory
+
+import (
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+type Inventory struct {
+	Host     Host        `json:"host"`
+	Cpu      Cpu         `json:"cpu"`
+	Memory   Memory      `json:"memory"`
+	Disks    []Disk      `json:"disks"`
+	Network  []Interface `json:"network"`
+	Packages []Package   `json:"packages"`
+	Releases []Release   `json:"releases"`
+}
+
+type Host struct {
+	Hostname        string `json:"hostname"`
+	OS              string `json:"os"`
+	Platform        string `json:"platform"`
+	PlatformVersion string `json:"platformVersion"`
+	KernelVersion   string `json:"kernelVersion"`
+	KernelArch      string `json:"kernelArch"`
+	BootTime        uint64 `json:"bootTime"`
+	// Em segundos; não é considerado na comparação entre coletas
+	Uptime uint64 `json:"uptime"`
+}
+
+type Cpu struct {
+	Model   string  `json:"model"`
+	Cores   int     `json:"cores"`
+	Threads int     `json:"threads"`
+	Mhz     float64 `json:"mhz"`
+}
+
+type Memory struct {
+	Total     uint64 `json:"total"`
+	SwapTotal uint64 `json:"swapTotal"`
+}
+
+type Disk struct {
+	Device     string `json:"device"`
+	Mountpoint string `json:"mountpoint"`
+	Fstype     string `json:"fstype"`
+	Total      uint64 `json:"total"`
+	// Não é considerado na comparação entre coletas
+	Used uint64 `json:"used"`
+}
+
+type Interface struct {
+	Name  string   `json:"name"`
+	Mac   string   `json:"mac"`
+	Addrs []string `json:"addrs"`
+	Up    bool     `json:"up"`
+}
+
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Release é uma implantação registrada no histórico local do agente.
+type Release struct {
+	IdImplantacao int       `json:"idImplantacao"`
+	Versao        string    `json:"versao"`
+	Status        string    `json:"status"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Collect reúne o inventário da máquina. Falhas em uma seção não impedem a
+// coleta das demais; a seção fica vazia.
+func Collect(ctx context.Context, journal *pubsub.ImplantacaoJournal, withPackages bool) Inventory {
+	var inv Inventory
+
+	if info, err := host.InfoWithContext(ctx); err == nil {
+		inv.Host = Host{
+			Hostname:        info.Hostname,
+			OS:              info.OS,
+			Platform:        info.Platform,
+			PlatformVersion: info.PlatformVersion,
+			KernelVersion:   info.KernelVersion,
+			KernelArch:      info.KernelArch,
+			BootTime:        info.BootTime,
+			Uptime:          info.Uptime,
+		}
+	}
+
+	if infos, err := cpu.InfoWithContext(ctx); err == nil && len(infos) > 0 {
+		inv.Cpu.Model = infos[0].ModelName
+		inv.Cpu.Mhz = infos[0].Mhz
+	}
+
+	if cores, err := cpu.CountsWithContext(ctx, false); err == nil {
+		inv.Cpu.Cores = cores
+	}
+
+	if threads, err := cpu.CountsWithContext(ctx, true); err == nil {
+		inv.Cpu.Threads = threads
+	}
+
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		inv.Memory.Total = vm.Total
+	}
+
+	if swap, err := mem.SwapMemoryWithContext(ctx); err == nil {
+		inv.Memory.SwapTotal = swap.Total
+	}
+
+	if partitions, err := disk.PartitionsWithContext(ctx, false); err == nil {
+		for _, partition := range partitions {
+			d := Disk{
+				Device:     partition.Device,
+				Mountpoint: partition.Mountpoint,
+				Fstype:     partition.Fstype,
+			}
+
+			if usage, err := disk.UsageWithContext(ctx, partition.Mountpoint); err == nil {
+				d.Total = usage.Total
+				d.Used = usage.Used
+			}
+
+			inv.Disks = append(inv.Disks, d)
+		}
+	}
+
+	if ifaces, err := net.InterfacesWithContext(ctx); err == nil {
+		for _, ifi := range ifaces {
+			i := Interface{
+				Name: ifi.Name,
+				Mac:  ifi.HardwareAddr,
+			}
+
+			for _, flag := range ifi.Flags {
+				if flag == "up" {
+					i.Up = true
+				}
+			}
+
+			for _, addr := range ifi.Addrs {
+				i.Addrs = append(i.Addrs, addr.Addr)
+			}
+
+			inv.Network = append(inv.Network, i)
+		}
+	}
+
+	if withPackages {
+		if packages, err := installedPackages(ctx); err == nil {
+			sort.Slice(packages, func(a, b int) bool {
+				return packages[a].Name < packages[b].Name
+			})
+
+			inv.Packages = packages
+		}
+	}
+
+	if journal != nil {
+		for _, entry := range journal.Entries() {
+			inv.Releases = append(inv.Releases, Release{
+				IdImplantacao: entry.IdImplantacao,
+				Versao:        entry.Versao,
+				Status:        entry.Status,
+				UpdatedAt:     entry.UpdatedAt,
+			})
+		}
+
+		// Implantações sem id ficam em ordem cronológica
+		sort.Slice(inv.Releases, func(a, b int) bool {
+			ra, rb := inv.Releases[a], inv.Releases[b]
+
+			if ra.IdImplantacao != rb.IdImplantacao {
+				return ra.IdImplantacao < rb.IdImplantacao
+			}
+
+			return ra.UpdatedAt.Before(rb.UpdatedAt)
+		})
+	}
+
+	return inv
+}
+
+// Sections separa o inventário pelas chaves JSON de cada seção.
+func (inv Inventory) Sections() (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(inv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sections map[string]json.RawMessage
+
+	err = json.Unmarshal(data, &sections)
+
+	return sections, err
+}
+
+// Diff retorna as seções que mudaram em relação à coleta anterior,
+// ignorando os campos que variam continuamente (uptime e uso de disco).
+func Diff(previous Inventory, current Inventory) (map[string]json.RawMessage, error) {
+	before, err := previous.stable().Sections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := current.stable().Sections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := current.Sections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]json.RawMessage)
+
+	for name, value := range after {
+		if string(before[name]) != string(value) {
+			changed[name] = sections[name]
+		}
+	}
+
+	return changed, nil
+}
+
+func (inv Inventory) stable() Inventory {
+	inv.Host.Uptime = 0
+
+	disks := make([]Disk, len(inv.Disks))
+
+	for i, d := range inv.Disks {
+		d.Used = 0
+		disks[i] = d
+	}
+
+	inv.Disks = disks
+
+	return inv
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Gerenciadores de pacotes consultados, na ordem
+var packageQueries = [][]string{
+	{"dpkg-query", "-W", "-f", "${Package}\t${Version}\n"},
+	{"rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\n"},
+	{"apk", "info", "-v"},
+}
+
+func installedPackages(ctx context.Context) ([]Package, error) {
+	for _, query := range packageQueries {
+		if _, err := exec.LookPath(query[0]); err != nil {
+			continue
+		}
+
+		output, err := exec.CommandContext(ctx, query[0], query[1:]...).Output()
+
+		if err != nil {
+			continue
+		}
+
+		return parsePackages(output, query[0] == "apk"), nil
+	}
+
+	return nil, errors.New("nenhum gerenciador de pacotes encontrado")
+}
+
+func parsePackages(output []byte, apk bool) []Package {
+	var packages []Package
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		// O apk imprime nome-versao-release
+		if apk {
+			parts := strings.Split(line, "-")
+
+			if len(parts) >= 3 {
+				packages = append(packages, Package{
+					Name:    strings.Join(parts[:len(parts)-2], "-"),
+					Version: strings.Join(parts[len(parts)-2:], "-"),
+				})
+			}
+
+			continue
+		}
+
+		name, version, _ := strings.Cut(line, "\t")
+
+		packages = append(packages, Package{Name: name, Version: version})
+	}
+
+	return packages
+}
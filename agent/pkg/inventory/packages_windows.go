@@ -0,0 +1,71 @@
+//go:build windows
+
+package inventory
+
+import (
+	"context"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Chaves do registro com os programas instalados (64 e 32 bits)
+var uninstallKeys = []string{
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+func installedPackages(ctx context.Context) ([]Package, error) {
+	var packages []Package
+
+	seen := make(map[Package]bool)
+
+	for _, path := range uninstallKeys {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.ENUMERATE_SUB_KEYS)
+
+		if err != nil {
+			continue
+		}
+
+		names, err := key.ReadSubKeyNames(-1)
+		key.Close()
+
+		if err != nil {
+			continue
+		}
+
+		for _, name := range names {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			pkg, ok := readPackage(path + `\` + name)
+
+			if ok && !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	return packages, nil
+}
+
+func readPackage(path string) (Package, bool) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+
+	if err != nil {
+		return Package{}, false
+	}
+
+	defer key.Close()
+
+	name, _, err := key.GetStringValue("DisplayName")
+
+	if err != nil || name == "" {
+		return Package{}, false
+	}
+
+	version, _, _ := key.GetStringValue("DisplayVersion")
+
+	return Package{Name: name, Version: version}, true
+}
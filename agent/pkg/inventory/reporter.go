@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Reporter envia o inventário completo a cada conexão e, periodicamente,
+// apenas as seções que mudaram.
+type Reporter struct {
+	ps      *pubsub.PubSub
+	journal *pubsub.ImplantacaoJournal
+	cfg     config.InventoryConfig
+	mu      sync.Mutex
+	last    *Inventory
+}
+
+func NewReporter(ps *pubsub.PubSub, journal *pubsub.ImplantacaoJournal, cfg config.InventoryConfig) *Reporter {
+	return &Reporter{
+		ps:      ps,
+		journal: journal,
+		cfg:     cfg,
+	}
+}
+
+// OnConnect envia o inventário completo, já que o servidor pode não ter
+// recebido os diffs enviados antes da queda da conexão.
+func (r *Reporter) OnConnect(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv := Collect(ctx, r.journal, r.cfg.Packages)
+
+	sections, err := inv.Sections()
+
+	if err != nil {
+		fmt.Println("Erro ao serializar inventário:", err)
+		return
+	}
+
+	if r.publish(pubsub.InventarioCompleto, sections) {
+		r.last = &inv
+	}
+}
+
+// Run coleta o inventário periodicamente até o contexto ser cancelado.
+func (r *Reporter) Run(ctx context.Context) {
+	if r.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(r.cfg.Interval) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.report(ctx)
+		}
+	}
+}
+
+func (r *Reporter) report(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Sem um inventário completo aceito, aguarda a próxima conexão
+	if r.last == nil {
+		return
+	}
+
+	inv := Collect(ctx, r.journal, r.cfg.Packages)
+
+	changed, err := Diff(*r.last, inv)
+
+	if err != nil {
+		fmt.Println("Erro ao comparar inventário:", err)
+		return
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	if r.publish(pubsub.InventarioDiff, changed) {
+		r.last = &inv
+	}
+}
+
+func (r *Reporter) publish(tipo string, sections map[string]json.RawMessage) bool {
+	data, err := json.Marshal(pubsub.AgenteInventoryPayload{
+		Tipo:       tipo,
+		ColetadoEm: time.Now(),
+		Secoes:     sections,
+	})
+
+	if err != nil {
+		fmt.Println("Erro ao serializar inventário:", err)
+		return false
+	}
+
+	err = r.ps.Publish(pubsub.AgenteInventoryEvent, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar inventário:", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Collector amostra as métricas da máquina, publica os agregados de cada
+// período e dispara alertas quando os limites configurados são excedidos.
+type Collector struct {
+	ps  *pubsub.PubSub
+	cfg config.MetricsConfig
+
+	sampler sampler
+	samples []sample
+
+	mu sync.Mutex
+	// Agregados aguardando envio, do mais antigo para o mais recente
+	buffer []pubsub.AgenteMetricsPayload
+	// Alertas ativos, pela chave tipo:recurso
+	alerts map[string]bool
+}
+
+func NewCollector(ps *pubsub.PubSub, cfg config.MetricsConfig) *Collector {
+	return &Collector{
+		ps:     ps,
+		cfg:    cfg,
+		alerts: make(map[string]bool),
+	}
+}
+
+// Run amostra e publica as métricas até o contexto ser cancelado.
+func (c *Collector) Run(ctx context.Context) {
+	if c.cfg.SampleInterval <= 0 {
+		return
+	}
+
+	sampleTicker := time.NewTicker(time.Duration(c.cfg.SampleInterval) * time.Second)
+	defer sampleTicker.Stop()
+
+	publishInterval := max(c.cfg.PublishInterval, c.cfg.SampleInterval)
+
+	publishTicker := time.NewTicker(time.Duration(publishInterval) * time.Second)
+	defer publishTicker.Stop()
+
+	// A primeira amostra apenas inicializa os contadores de CPU e rede
+	c.sampler.sample(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sampleTicker.C:
+			c.samples = append(c.samples, c.sampler.sample(ctx))
+		case <-publishTicker.C:
+			if len(c.samples) == 0 {
+				continue
+			}
+
+			payload := aggregate(c.samples)
+			payload.Eventos = eventStats(c.ps.DispatchStats())
+			c.samples = nil
+
+			c.checkAlerts(payload)
+			c.enqueue(payload)
+			c.Flush()
+		}
+	}
+}
+
+// OnConnect envia os agregados acumulados enquanto o agente estava
+// desconectado.
+func (c *Collector) OnConnect(ctx context.Context) {
+	c.Flush()
+}
+
+func (c *Collector) enqueue(payload pubsub.AgenteMetricsPayload) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buffer = append(c.buffer, payload)
+
+	// Descarta os mais antigos quando o buffer enche
+	if size := max(c.cfg.BufferSize, 1); len(c.buffer) > size {
+		c.buffer = c.buffer[len(c.buffer)-size:]
+	}
+}
+
+// Flush publica os agregados pendentes, na ordem, até o primeiro erro.
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.buffer) > 0 {
+		data, err := json.Marshal(c.buffer[0])
+
+		if err != nil {
+			fmt.Println("Erro ao serializar métricas:", err)
+			c.buffer = c.buffer[1:]
+			continue
+		}
+
+		err = c.ps.Publish(pubsub.AgenteMetricsEvent, string(data))
+
+		if err != nil {
+			return
+		}
+
+		c.buffer = c.buffer[1:]
+	}
+}
+
+func (c *Collector) checkAlerts(payload pubsub.AgenteMetricsPayload) {
+	c.checkAlert("cpu", "", payload.Cpu.Avg, c.cfg.CpuThreshold, payload.Fim)
+	c.checkAlert("memoria", "", payload.Memoria.Avg, c.cfg.MemoryThreshold, payload.Fim)
+
+	for mount, percent := range payload.Discos {
+		c.checkAlert("disco", mount, percent, c.cfg.DiskThreshold, payload.Fim)
+	}
+}
+
+// checkAlert publica o alerta apenas nas transições entre ativo e
+// normalizado. Os alertas usam o outbox para não se perderem em quedas.
+func (c *Collector) checkAlert(tipo string, recurso string, valor float64, limite float64, em time.Time) {
+	if limite <= 0 {
+		return
+	}
+
+	key := tipo + ":" + recurso
+	active := valor > limite
+
+	if active == c.alerts[key] {
+		return
+	}
+
+	c.alerts[key] = active
+
+	estado := pubsub.AlertaNormalizado
+
+	if active {
+		estado = pubsub.AlertaAtivo
+	}
+
+	data, err := json.Marshal(pubsub.AgenteAlertPayload{
+		Tipo:    tipo,
+		Recurso: recurso,
+		Estado:  estado,
+		Valor:   round(valor),
+		Limite:  limite,
+		Em:      em,
+	})
+
+	if err != nil {
+		fmt.Println("Erro ao serializar alerta:", err)
+		return
+	}
+
+	err = c.ps.PublishDurable(pubsub.AgenteAlertEvent, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar alerta:", err)
+	}
+}
+
+func aggregate(samples []sample) pubsub.AgenteMetricsPayload {
+	last := samples[len(samples)-1]
+
+	payload := pubsub.AgenteMetricsPayload{
+		Inicio:   samples[0].at,
+		Fim:      last.at,
+		Amostras: len(samples),
+		Discos:   make(map[string]float64, len(last.disks)),
+		Load:     last.load,
+	}
+
+	cpu := make([]float64, len(samples))
+	memory := make([]float64, len(samples))
+
+	var rx, tx float64
+
+	for i, smp := range samples {
+		cpu[i] = smp.cpu
+		memory[i] = smp.mem
+		rx += smp.rx
+		tx += smp.tx
+		payload.Processos = max(payload.Processos, smp.processes)
+	}
+
+	payload.Cpu = stat(cpu)
+	payload.Memoria = stat(memory)
+	payload.RedeRx = math.Round(rx / float64(len(samples)))
+	payload.RedeTx = math.Round(tx / float64(len(samples)))
+
+	for mount, percent := range last.disks {
+		payload.Discos[mount] = round(percent)
+	}
+
+	for i := range payload.Load {
+		payload.Load[i] = round(payload.Load[i])
+	}
+
+	return payload
+}
+
+func eventStats(stats map[string]pubsub.DispatchStats) map[string]pubsub.EventoStat {
+	eventos := make(map[string]pubsub.EventoStat, len(stats))
+
+	for event, st := range stats {
+		evento := pubsub.EventoStat{
+			Fila:        st.QueueDepth,
+			Processados: st.Processed,
+			Descartados: st.Dropped,
+			LatenciaMax: round(float64(st.MaxLatency) / float64(time.Millisecond)),
+		}
+
+		if st.Processed > 0 {
+			avg := float64(st.TotalLatency) / float64(st.Processed)
+			evento.LatenciaMedia = round(avg / float64(time.Millisecond))
+		}
+
+		eventos[event] = evento
+	}
+
+	return eventos
+}
+
+func stat(values []float64) pubsub.MetricStat {
+	s := pubsub.MetricStat{Min: values[0], Max: values[0]}
+
+	var sum float64
+
+	for _, v := range values {
+		s.Min = min(s.Min, v)
+		s.Max = max(s.Max, v)
+		sum += v
+	}
+
+	s.Avg = sum / float64(len(values))
+
+	return pubsub.MetricStat{Min: round(s.Min), Avg: round(s.Avg), Max: round(s.Max)}
+}
+
+// round mantém duas casas decimais para deixar o payload compacto.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+type sample struct {
+	at        time.Time
+	cpu       float64
+	mem       float64
+	disks     map[string]float64
+	load      [3]float64
+	rx        float64
+	tx        float64
+	processes int
+}
+
+// sampler guarda os contadores da amostra anterior para calcular a vazão
+// de rede.
+type sampler struct {
+	lastRx uint64
+	lastTx uint64
+	lastAt time.Time
+}
+
+func (s *sampler) sample(ctx context.Context) sample {
+	smp := sample{
+		at:    time.Now(),
+		disks: make(map[string]float64),
+	}
+
+	// Com intervalo zero o uso é calculado desde a chamada anterior
+	if percents, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(percents) > 0 {
+		smp.cpu = percents[0]
+	}
+
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		smp.mem = vm.UsedPercent
+	}
+
+	if partitions, err := disk.PartitionsWithContext(ctx, false); err == nil {
+		for _, partition := range partitions {
+			usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+
+			if err != nil || usage.Total == 0 {
+				continue
+			}
+
+			smp.disks[partition.Mountpoint] = usage.UsedPercent
+		}
+	}
+
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		smp.load = [3]float64{avg.Load1, avg.Load5, avg.Load15}
+	}
+
+	if counters, err := net.IOCountersWithContext(ctx, false); err == nil && len(counters) > 0 {
+		rx, tx := counters[0].BytesRecv, counters[0].BytesSent
+
+		if !s.lastAt.IsZero() && rx >= s.lastRx && tx >= s.lastTx {
+			elapsed := smp.at.Sub(s.lastAt).Seconds()
+
+			if elapsed > 0 {
+				smp.rx = float64(rx-s.lastRx) / elapsed
+				smp.tx = float64(tx-s.lastTx) / elapsed
+			}
+		}
+
+		s.lastRx, s.lastTx, s.lastAt = rx, tx, smp.at
+	}
+
+	if pids, err := process.PidsWithContext(ctx); err == nil {
+		smp.processes = len(pids)
+	}
+
+	return smp
+}
@@ -0,0 +1,315 @@
+package proxy
+
+import (
+	"agent/pkg/config"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mu      sync.RWMutex
+	current = http.ProxyFromEnvironment
+
+	transport = newTransport()
+)
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = FromRequest
+
+	return t
+}
+
+// Configure define o proxy usado pelas conexões do agente (WebSocket, API e
+// download de artefatos). Sem uma URL configurada, as variáveis de ambiente
+// HTTPS_PROXY, HTTP_PROXY e NO_PROXY são respeitadas.
+func Configure(cfg config.ProxyConfig) error {
+	proxyFunc := http.ProxyFromEnvironment
+
+	if cfg.Url != "" {
+		proxyUrl, err := url.Parse(cfg.Url)
+
+		if err != nil {
+			return fmt.Errorf("url do proxy inválida: %w", err)
+		}
+
+		if proxyUrl.Scheme != "http" && proxyUrl.Scheme != "https" {
+			return fmt.Errorf("esquema do proxy não suportado: %s", proxyUrl.Scheme)
+		}
+
+		if proxyUrl.User == nil && cfg.Username != "" {
+			proxyUrl.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+
+		noProxy := parseNoProxy(cfg.NoProxy)
+
+		proxyFunc = func(req *http.Request) (*url.URL, error) {
+			if bypass(req.URL, noProxy) {
+				return nil, nil
+			}
+
+			return proxyUrl, nil
+		}
+	}
+
+	mu.Lock()
+	current = proxyFunc
+	mu.Unlock()
+
+	return nil
+}
+
+// FromRequest retorna o proxy a ser usado na requisição. Pode ser usado
+// diretamente como http.Transport.Proxy. No WebSocket use DialContext, já
+// que o gorilla/websocket só aceita proxies http.
+func FromRequest(req *http.Request) (*url.URL, error) {
+	mu.RLock()
+	proxyFunc := current
+	mu.RUnlock()
+
+	return proxyFunc(req)
+}
+
+// Client retorna um cliente HTTP que respeita o proxy configurado.
+func Client() *http.Client {
+	return &http.Client{Transport: transport}
+}
+
+// DialContext retorna uma função para websocket.Dialer.NetDialContext que
+// abre o túnel até o destino com CONNECT, inclusive quando o proxy usa
+// https. scheme é o esquema do destino (ws, wss, http ou https).
+func DialContext(scheme string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if scheme == "wss" || scheme == "https" {
+		scheme = "https"
+	} else {
+		scheme = "http"
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := net.Dialer{Timeout: 30 * time.Second}
+
+		proxyUrl, err := FromRequest(&http.Request{
+			URL: &url.URL{Scheme: scheme, Host: addr},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if proxyUrl == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		if proxyUrl.Scheme != "http" && proxyUrl.Scheme != "https" {
+			return nil, fmt.Errorf("esquema do proxy não suportado: %s", proxyUrl.Scheme)
+		}
+
+		proxyAddr := proxyUrl.Host
+
+		if proxyUrl.Port() == "" {
+			proxyAddr = net.JoinHostPort(proxyUrl.Hostname(), defaultPort(proxyUrl.Scheme))
+		}
+
+		conn, err := dialer.DialContext(ctx, network, proxyAddr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if proxyUrl.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyUrl.Hostname()})
+
+			err = tlsConn.HandshakeContext(ctx)
+
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("erro no handshake TLS com o proxy: %w", err)
+			}
+
+			conn = tlsConn
+		}
+
+		tunnel, err := connect(ctx, conn, proxyUrl, addr)
+
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return tunnel, nil
+	}
+}
+
+// bufferedConn devolve primeiro os bytes lidos junto com a resposta do
+// proxy.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// connect pede ao proxy um túnel até addr.
+func connect(ctx context.Context, conn net.Conn, proxyUrl *url.URL, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	header := make(http.Header)
+
+	if user := proxyUrl.User; user != nil {
+		password, _ := user.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: header,
+	}
+
+	err := req.Write(conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	// O corpo não é fechado: depois da resposta os bytes já pertencem ao
+	// túnel
+	resp, err := http.ReadResponse(reader, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy recusou a conexão: %s", resp.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+type noProxyRule struct {
+	// "*" desativa o proxy para todos os hosts
+	all     bool
+	network *net.IPNet
+	ip      net.IP
+	domain  string
+	port    string
+}
+
+func parseNoProxy(value string) []noProxyRule {
+	var rules []noProxyRule
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			rules = append(rules, noProxyRule{all: true})
+			continue
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			rules = append(rules, noProxyRule{network: network})
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(entry)
+
+		if err != nil {
+			host, port = entry, ""
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			rules = append(rules, noProxyRule{ip: ip, port: port})
+			continue
+		}
+
+		host = strings.TrimPrefix(host, "*")
+		host = strings.TrimPrefix(host, ".")
+
+		rules = append(rules, noProxyRule{domain: host, port: port})
+	}
+
+	return rules
+}
+
+// bypass informa se o destino não deve passar pelo proxy. Assim como em
+// http.ProxyFromEnvironment, localhost e endereços de loopback nunca usam
+// o proxy.
+func bypass(target *url.URL, rules []noProxyRule) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+
+	if port == "" {
+		port = defaultPort(target.Scheme)
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	for _, rule := range rules {
+		if rule.port != "" && rule.port != port {
+			continue
+		}
+
+		switch {
+		case rule.all:
+			return true
+		case rule.network != nil:
+			if ip != nil && rule.network.Contains(ip) {
+				return true
+			}
+		case rule.ip != nil:
+			if ip != nil && rule.ip.Equal(ip) {
+				return true
+			}
+		case rule.domain != "":
+			if host == rule.domain || strings.HasSuffix(host, "."+rule.domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return "443"
+	default:
+		return "80"
+	}
+}
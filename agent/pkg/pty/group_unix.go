@@ -0,0 +1,56 @@
+//go:build !windows
+
+package pty
+
+import (
+	"syscall"
+)
+
+// O shell é iniciado com setsid, então ele é líder do próprio grupo de
+// processos e os filhos herdam o mesmo pgid.
+var terminationSignals = []syscall.Signal{
+	syscall.SIGHUP,
+	syscall.SIGTERM,
+	syscall.SIGKILL,
+}
+
+type processGroup struct {
+	pgid int
+}
+
+func newProcessGroup() (*processGroup, error) {
+	return &processGroup{}, nil
+}
+
+// attach associa o grupo ao do shell iniciado.
+func (g *processGroup) attach(pid int) error {
+	pgid, err := syscall.Getpgid(pid)
+
+	if err != nil {
+		return err
+	}
+
+	g.pgid = pgid
+
+	return nil
+}
+
+func (g *processGroup) signal(sig syscall.Signal) error {
+	err := syscall.Kill(-g.pgid, sig)
+
+	if err == syscall.ESRCH {
+		return nil
+	}
+
+	return err
+}
+
+// alive informa se ainda existe algum processo no grupo, incluindo o shell
+// enquanto ele não foi aguardado.
+func (g *processGroup) alive() bool {
+	return syscall.Kill(-g.pgid, 0) != syscall.ESRCH
+}
+
+func (g *processGroup) close() error {
+	return nil
+}
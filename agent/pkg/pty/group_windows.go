@@ -0,0 +1,111 @@
+//go:build windows
+
+package pty
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// No Windows não existe escalonamento de sinais: o job object é encerrado
+// de uma vez, levando junto todos os processos filhos do shell.
+var terminationSignals = []syscall.Signal{
+	syscall.SIGKILL,
+}
+
+// JOBOBJECT_BASIC_ACCOUNTING_INFORMATION
+type jobAccounting struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
+
+// processGroup é um job object nomeado. O shell é iniciado pelo winpty,
+// então o job não pode ser atribuído na criação do processo: o winpty
+// executa o launcher (RunLauncher), que entra no job pelo nome antes de
+// iniciar o shell.
+type processGroup struct {
+	job  windows.Handle
+	name string
+}
+
+func newProcessGroup() (*processGroup, error) {
+	id := make([]byte, 16)
+
+	_, err := rand.Read(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := `Local\vrdeploy-pty-` + hex.EncodeToString(id)
+
+	namePtr, err := windows.UTF16PtrFromString(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := windows.CreateJobObject(nil, namePtr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+
+	_, err = windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	)
+
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+
+	return &processGroup{job: job, name: name}, nil
+}
+
+// attach não tem efeito no Windows: o launcher já entrou no job.
+func (g *processGroup) attach(pid int) error {
+	return nil
+}
+
+func (g *processGroup) signal(sig syscall.Signal) error {
+	return windows.TerminateJobObject(g.job, 1)
+}
+
+// alive informa se ainda existe algum processo no job.
+func (g *processGroup) alive() bool {
+	var info jobAccounting
+
+	err := windows.QueryInformationJobObject(
+		g.job,
+		windows.JobObjectBasicAccountingInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+		nil,
+	)
+
+	return err == nil && info.ActiveProcesses > 0
+}
+
+func (g *processGroup) close() error {
+	return windows.CloseHandle(g.job)
+}
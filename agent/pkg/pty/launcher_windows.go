@@ -0,0 +1,75 @@
+//go:build windows
+
+package pty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Comando oculto do agente executado pelo winpty no lugar do shell
+const LauncherCommand = "pty-launcher"
+
+const jobObjectAssignProcess = 0x0001
+
+var procOpenJobObject = windows.NewLazySystemDLL("kernel32.dll").NewProc("OpenJobObjectW")
+
+// RunLauncher entra no job object da sessão e só então inicia o shell, para
+// que nenhum processo filho seja criado fora do job. Retorna o código de
+// saída do shell.
+func RunLauncher(job string, args []string) (int, error) {
+	if len(args) == 0 {
+		return -1, errors.New("shell não informado")
+	}
+
+	name, err := windows.UTF16PtrFromString(job)
+
+	if err != nil {
+		return -1, err
+	}
+
+	handle, _, err := procOpenJobObject.Call(
+		jobObjectAssignProcess,
+		0,
+		uintptr(unsafe.Pointer(name)),
+	)
+
+	if handle == 0 {
+		return -1, err
+	}
+
+	defer windows.CloseHandle(windows.Handle(handle))
+
+	err = windows.AssignProcessToJobObject(windows.Handle(handle), windows.CurrentProcess())
+
+	if err != nil {
+		return -1, err
+	}
+
+	// O Ctrl+C digitado no terminal é destinado ao shell
+	signal.Ignore(os.Interrupt)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+
+	if err != nil {
+		return -1, err
+	}
+
+	return 0, nil
+}
@@ -1,126 +1,230 @@
 package pty
 
 import (
+	"agent/pkg/config"
 	"agent/pkg/pubsub"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"sync"
 )
 
+// Entrada descartada corromperia a sessão interativa, que é encerrada
+var errInputOverflow = errors.New("entrada do terminal descartada: fila de processamento cheia")
+
 type PtyManager struct {
 	sessions map[int]*PtySession
 	mu       sync.RWMutex
 	ps       *pubsub.PubSub
+	cfg      config.TerminalConfig
 }
 
-func NewPtyManager(ps *pubsub.PubSub) *PtyManager {
+func NewPtyManager(ps *pubsub.PubSub, cfg config.TerminalConfig) *PtyManager {
 	return &PtyManager{
 		sessions: make(map[int]*PtySession),
 		ps:       ps,
+		cfg:      cfg,
 	}
 }
 
 // TODO: Change to use sessionId instead of idAgente
-func (pm *PtyManager) HandleSessionStarted(ctx context.Context) pubsub.EventHandler {
-	return func(data string) {
-		fmt.Println("Iniciando nova sessão pty com dados:", data)
+func (pm *PtyManager) HandleSessionStarted(ctx context.Context, payload pubsub.PtySessionStartedPayload) {
+	fmt.Println("Iniciando nova sessão pty:", payload.IdAgente)
+
+	spec, err := resolveShell(pm.cfg, payload)
+
+	if err != nil {
+		fmt.Println("Sessão pty recusada:", err)
+		pm.publishEnded(pubsub.PtySessionEndedPayload{
+			IdAgente: payload.IdAgente,
+			ExitCode: -1,
+			Reason:   err.Error(),
+		})
+		return
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if _, exists := pm.sessions[payload.IdAgente]; exists {
+		fmt.Println("Sessão já existe:", payload.IdAgente)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	session := &PtySession{
+		ctx:        ctx,
+		cancel:     cancel,
+		inputChan:  make(chan []byte, 100),
+		outputChan: make(chan []byte, 100),
+		closeChan:  make(chan struct{}),
+		outputDone: make(chan struct{}),
+		done:       make(chan struct{}),
+		idAgente:   payload.IdAgente,
+	}
+
+	pm.sessions[payload.IdAgente] = session
+
+	go pm.handleOutput(session)
 
-		var payload pubsub.PtySessionStartedPayload
+	go func() {
+		defer close(session.done)
 
-		err := json.Unmarshal([]byte(data), &payload)
+		state, err := Start(ctx, spec, session.inputChan, session.outputChan, session.closeChan)
 
 		if err != nil {
-			fmt.Println("Erro ao parsear payload:", err)
-			return
+			log.Println("Erro ao executar sessão pty:", err)
 		}
 
+		// Garante que toda a saída foi publicada antes do encerramento
+		<-session.outputDone
+
 		pm.mu.Lock()
-		defer pm.mu.Unlock()
+		delete(pm.sessions, payload.IdAgente)
+		pm.mu.Unlock()
 
-		if _, exists := pm.sessions[payload.IdAgente]; exists {
-			fmt.Println("Sessão já existe:", payload.IdAgente)
-			return
+		log.Println("Sessão pty encerrada:", payload.IdAgente)
+
+		ended := pubsub.PtySessionEndedPayload{
+			IdAgente: payload.IdAgente,
+			ExitCode: -1,
 		}
 
-		ctx, cancel := context.WithCancel(ctx)
+		if state != nil {
+			ended.ExitCode = state.ExitCode()
+		}
 
-		session := &PtySession{
-			ctx:        ctx,
-			cancel:     cancel,
-			inputChan:  make(chan []byte, 100),
-			outputChan: make(chan []byte, 100),
-			closeChan:  make(chan struct{}),
-			idAgente:   payload.IdAgente,
+		if cause := context.Cause(ctx); cause != nil {
+			ended.Reason = cause.Error()
+		} else if err != nil {
+			ended.Reason = err.Error()
 		}
 
-		pm.sessions[payload.IdAgente] = session
+		pm.publishEnded(ended)
+	}()
+}
 
-		go pm.handleOutput(session)
+func (pm *PtyManager) publishEnded(payload pubsub.PtySessionEndedPayload) {
+	// Servidores sem a capacidade recebem apenas o id do agente
+	if !pm.ps.Protocol().Has(pubsub.CapabilityPtyEnded) {
+		pm.ps.Publish(pubsub.PtySessionEndedEvent, strconv.Itoa(payload.IdAgente))
+		return
+	}
 
-		go func() {
-			defer func() {
-				pm.mu.Lock()
-				delete(pm.sessions, payload.IdAgente)
-				pm.mu.Unlock()
+	data, err := json.Marshal(payload)
 
-				log.Println("Sessão pty encerrada:", payload.IdAgente)
+	if err != nil {
+		fmt.Println("Erro ao serializar encerramento da sessão:", err)
+		return
+	}
 
-				pm.ps.Publish(pubsub.PtySessionEndedEvent, fmt.Sprintf("%d", payload.IdAgente))
-			}()
+	pm.ps.Publish(pubsub.PtySessionEndedEvent, string(data))
+}
 
-			Start(ctx, session.inputChan, session.outputChan, session.closeChan)
-		}()
+func (pm *PtyManager) HandleSessionClose(ctx context.Context, payload pubsub.PtySessionClosePayload) {
+	reason := payload.Reason
+
+	if reason == "" {
+		reason = "sessão encerrada pelo servidor"
+	}
+
+	if !pm.Close(payload.IdAgente, errors.New(reason)) {
+		fmt.Println("Sessão não encontrada:", payload.IdAgente)
 	}
 }
 
-func (pm *PtyManager) HandleInput() pubsub.EventHandler {
-	return func(data string) {
-		var payload pubsub.PtyInputPayload
+// Close solicita o encerramento da sessão e aguarda até que o shell e todos
+// os seus processos filhos tenham sido finalizados.
+func (pm *PtyManager) Close(idAgente int, reason error) bool {
+	pm.mu.RLock()
+	session, exists := pm.sessions[idAgente]
+	pm.mu.RUnlock()
 
-		err := json.Unmarshal([]byte(data), &payload)
+	if !exists {
+		return false
+	}
 
-		if err != nil {
-			fmt.Println("Erro ao parsear payload:", err, data)
-			return
-		}
+	session.cancel(reason)
 
-		pm.mu.RLock()
-		session, exists := pm.sessions[payload.IdAgente]
-		pm.mu.RUnlock()
+	<-session.outputDone
 
-		if !exists {
-			fmt.Println("Sessão não encontrada:", payload.IdAgente)
-			return
-		}
+	return true
+}
+
+// CloseAll encerra todas as sessões abertas e aguarda a publicação dos seus
+// encerramentos, ou o cancelamento do contexto.
+func (pm *PtyManager) CloseAll(ctx context.Context, reason error) {
+	pm.mu.RLock()
+	sessions := make([]*PtySession, 0, len(pm.sessions))
 
-		log.Printf("Recebido input: %s", payload.Input)
+	for _, session := range pm.sessions {
+		sessions = append(sessions, session)
+	}
+
+	pm.mu.RUnlock()
+
+	for _, session := range sessions {
+		session.cancel(reason)
+	}
 
+	for _, session := range sessions {
 		select {
-		case session.inputChan <- []byte(payload.Input):
-		case <-session.ctx.Done():
-			fmt.Println("Sessão fechada:", payload.IdAgente)
-		default:
-			fmt.Println(
-				"Canal de input cheio, descartando dados para sessão:",
-				payload.IdAgente,
-			)
+		case <-session.done:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+func (pm *PtyManager) HandleInput(ctx context.Context, payload pubsub.PtyInputPayload) {
+	pm.mu.RLock()
+	session, exists := pm.sessions[payload.IdAgente]
+	pm.mu.RUnlock()
+
+	if !exists {
+		fmt.Println("Sessão não encontrada:", payload.IdAgente)
+		return
+	}
+
+	log.Printf("Recebido input: %s", payload.Input)
+
+	// Com o canal cheio a fila da sessão no dispatcher cresce até
+	// HandleInputOverflow encerrar a sessão
+	select {
+	case session.inputChan <- []byte(payload.Input):
+	case <-session.ctx.Done():
+		fmt.Println("Sessão fechada:", payload.IdAgente)
+	}
+}
+
+// HandleInputOverflow encerra a sessão cuja entrada foi descartada pelo
+// dispatcher. Não bloqueia.
+func (pm *PtyManager) HandleInputOverflow(ctx context.Context, payload pubsub.PtyInputPayload) {
+	pm.mu.RLock()
+	session, exists := pm.sessions[payload.IdAgente]
+	pm.mu.RUnlock()
+
+	if exists {
+		session.cancel(errInputOverflow)
+	}
+}
+
 func (pm *PtyManager) handleOutput(session *PtySession) {
+	defer close(session.outputDone)
+
 	for {
 		select {
 		case <-session.closeChan:
 			return
 		case output := <-session.outputChan:
-			err := pm.ps.Publish(pubsub.PtyOutputEvent, string(output))
+			err := pm.ps.PublishWait(context.Background(), pubsub.PtyOutputEvent, string(output))
 
 			if err != nil {
 				fmt.Println("Erro ao publicar saída do pty:", err)
-				return
 			}
 		}
 	}
@@ -0,0 +1,13 @@
+package pty
+
+import (
+	"io"
+	"os"
+)
+
+type process interface {
+	io.ReadWriteCloser
+	Pid() (int, error)
+	Kill() error
+	Wait() (*os.ProcessState, error)
+}
@@ -0,0 +1,141 @@
+//go:build !windows
+
+package pty
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+type unixProcess struct {
+	cmd  *exec.Cmd
+	file *os.File
+}
+
+func startProcess(spec ShellSpec, group *processGroup) (process, error) {
+	path, err := exec.LookPath(spec.Shell)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = os.Environ()
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+
+	// Convenção do login(1): argv[0] prefixado com "-"
+	if spec.Login {
+		cmd.Args[0] = "-" + filepath.Base(path)
+	}
+
+	if spec.User != "" {
+		u, err := user.Lookup(spec.User)
+
+		if err != nil {
+			return nil, err
+		}
+
+		credential, err := userCredential(u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.SysProcAttr.Credential = credential
+		cmd.Env = append(
+			cmd.Env,
+			"HOME="+u.HomeDir,
+			"USER="+u.Username,
+			"LOGNAME="+u.Username,
+		)
+
+		if cmd.Dir == "" {
+			cmd.Dir = u.HomeDir
+		}
+	}
+
+	cmd.Env = append(cmd.Env, "SHELL="+path)
+	cmd.Env = append(cmd.Env, spec.environ()...)
+
+	file, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: 120, Rows: 60})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &unixProcess{cmd: cmd, file: file}, nil
+}
+
+func userCredential(u *user.User) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &syscall.Credential{
+		Uid: uint32(uid),
+		Gid: uint32(gid),
+	}
+
+	groupIds, err := u.GroupIds()
+
+	if err != nil {
+		return credential, nil
+	}
+
+	for _, id := range groupIds {
+		group, err := strconv.ParseUint(id, 10, 32)
+
+		if err != nil {
+			continue
+		}
+
+		credential.Groups = append(credential.Groups, uint32(group))
+	}
+
+	return credential, nil
+}
+
+func (p *unixProcess) Read(b []byte) (int, error) {
+	return p.file.Read(b)
+}
+
+func (p *unixProcess) Write(b []byte) (int, error) {
+	return p.file.Write(b)
+}
+
+func (p *unixProcess) Close() error {
+	return p.file.Close()
+}
+
+func (p *unixProcess) Pid() (int, error) {
+	return p.cmd.Process.Pid, nil
+}
+
+func (p *unixProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+func (p *unixProcess) Wait() (*os.ProcessState, error) {
+	err := p.cmd.Wait()
+
+	if _, ok := err.(*exec.ExitError); ok {
+		err = nil
+	}
+
+	return p.cmd.ProcessState, err
+}
@@ -0,0 +1,56 @@
+//go:build windows
+
+package pty
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/runletapp/go-console"
+)
+
+func startProcess(spec ShellSpec, group *processGroup) (process, error) {
+	if spec.User != "" {
+		return nil, fmt.Errorf("execução como outro usuário não suportada no Windows")
+	}
+
+	proc, err := console.New(120, 60)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Dir != "" {
+		proc.SetCWD(spec.Dir)
+	}
+
+	proc.SetENV(spec.environ())
+
+	executable, err := os.Executable()
+
+	if err != nil {
+		proc.Close()
+		return nil, err
+	}
+
+	// O shell é iniciado pelo launcher dentro do job object da sessão. O
+	// winpty recebe a linha de comando como uma única string
+	args := append(
+		[]string{executable, LauncherCommand, "--job", group.name, "--", spec.Shell},
+		spec.Args...,
+	)
+
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") {
+			args[i] = `"` + arg + `"`
+		}
+	}
+
+	if err := proc.Start(args); err != nil {
+		proc.Close()
+		return nil, err
+	}
+
+	return proc, nil
+}
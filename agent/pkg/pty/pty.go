@@ -4,66 +4,98 @@ import (
 	"context"
 	"log"
 	"os"
-	"runtime"
 	"sync"
-
-	"github.com/runletapp/go-console"
+	"time"
 )
 
+// Tempo aguardado entre cada sinal enviado ao grupo de processos
+const terminationGrace = 3 * time.Second
+
+// Intervalo entre as verificações de processos restantes no grupo
+const groupPollInterval = 100 * time.Millisecond
+
 func Start(
 	ctx context.Context,
+	spec ShellSpec,
 	inputChan chan []byte,
 	outputChan chan []byte,
 	closeChan chan struct{},
-) {
-	proc, err := console.New(120, 60)
+) (*os.ProcessState, error) {
+	defer close(closeChan)
+
+	// No Windows o grupo precisa existir antes do shell, que é iniciado
+	// dentro dele
+	group, err := newProcessGroup()
 
 	if err != nil {
-		log.Fatalf("Erro ao criar console: %v", err)
+		return nil, err
 	}
 
-	defer proc.Close()
+	defer group.close()
+
+	proc, err := startProcess(spec, group)
 
-	var args []string
+	if err != nil {
+		return nil, err
+	}
 
-	if runtime.GOOS == "windows" {
-		// powershell has some issues like clearing the screen
-		// args = []string{"powershell.exe"}
-		args = []string{"cmd.exe"}
-	} else {
-		shell := os.Getenv("SHELL")
+	defer proc.Close()
 
-		if shell == "" {
-			shell = "bash"
-		}
+	pid, err := proc.Pid()
 
-		args = []string{shell}
+	if err != nil {
+		return nil, err
 	}
 
-	if err := proc.Start(args); err != nil {
-		log.Fatalf("Erro ao iniciar processo: %v", err)
+	err = group.attach(pid)
+
+	if err != nil {
+		log.Printf("Erro ao obter grupo de processos (pid %d): %v", pid, err)
+		group = nil
 	}
 
-	var closeOnce sync.Once
+	var (
+		state   *os.ProcessState
+		waitErr error
+	)
+
+	exited := make(chan struct{})
 
-	safeClose := func() {
-		closeOnce.Do(func() {
-			close(closeChan)
+	go func() {
+		defer close(exited)
+
+		state, waitErr = proc.Wait()
+	}()
+
+	// stop é fechado quando a leitura ou a escrita falham
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	var stopOnce sync.Once
+
+	safeStop := func() {
+		stopOnce.Do(func() {
+			close(stop)
 		})
 	}
 
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
 	go func() {
-		defer safeClose()
+		defer wg.Done()
+		defer safeStop()
 		for {
 			select {
-			case <-ctx.Done():
+			case <-done:
 				return
 			case data := <-inputChan:
 				if len(data) == 0 {
 					continue
 				}
 				if _, err := proc.Write(data); err != nil {
-					println("Erro ao escrever no processo:", err)
+					log.Println("Erro ao escrever no processo:", err)
 					return
 				}
 			}
@@ -71,30 +103,91 @@ func Start(
 	}()
 
 	go func() {
-		defer safeClose()
+		defer wg.Done()
+		defer safeStop()
 		buf := make([]byte, 1024)
 		for {
 			n, err := proc.Read(buf)
-			if err != nil {
-				println("Erro ao ler do processo:", err)
-				return
-			}
 			if n > 0 {
+				output := make([]byte, n)
+				copy(output, buf[:n])
+
 				select {
-				case outputChan <- buf[:n]:
-				case <-ctx.Done():
+				case outputChan <- output:
+				case <-done:
 					return
 				}
 			}
+			if err != nil {
+				return
+			}
 		}
 	}()
 
-	go func() {
-		defer safeClose()
-		if _, err := proc.Wait(); err != nil {
-			log.Printf("Process wait error: %v", err)
+	select {
+	case <-exited:
+	case <-stop:
+	case <-ctx.Done():
+	}
+
+	// Também quando o shell finaliza sozinho: processos do grupo que
+	// ignoram o SIGHUP (ex: iniciados com nohup) continuariam em execução
+	terminate(group, proc, exited)
+
+	<-exited
+
+	close(done)
+	proc.Close()
+	wg.Wait()
+
+	return state, waitErr
+}
+
+// terminate envia os sinais de encerramento ao grupo de processos do shell,
+// aguardando entre cada um deles até que nenhum processo do grupo reste.
+func terminate(group *processGroup, proc process, exited <-chan struct{}) {
+	if group == nil {
+		select {
+		case <-exited:
+			return
+		default:
 		}
-	}()
 
-	<-closeChan
+		if err := proc.Kill(); err != nil {
+			log.Println("Erro ao finalizar processo:", err)
+		}
+
+		return
+	}
+
+	for _, sig := range terminationSignals {
+		if !group.alive() {
+			return
+		}
+
+		if err := group.signal(sig); err != nil {
+			log.Printf("Erro ao enviar sinal %v ao grupo de processos: %v", sig, err)
+		}
+
+		if waitGroup(group, terminationGrace) {
+			return
+		}
+	}
+
+	log.Println("Processos do grupo ainda em execução após o último sinal")
+}
+
+// waitGroup aguarda até timeout que todos os processos do grupo finalizem.
+func waitGroup(group *processGroup, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	for group.alive() {
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(groupPollInterval)
+	}
+
+	return true
 }
@@ -4,9 +4,12 @@ import "context"
 
 type PtySession struct {
 	ctx        context.Context
-	cancel     context.CancelFunc
+	cancel     context.CancelCauseFunc
 	inputChan  chan []byte
 	outputChan chan []byte
 	closeChan  chan struct{}
-	idAgente   int
+	outputDone chan struct{}
+	// Fechado após a publicação do encerramento da sessão
+	done     chan struct{}
+	idAgente int
 }
@@ -0,0 +1,123 @@
+package pty
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"agent/pkg/system"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+)
+
+type ShellSpec struct {
+	Shell string
+	Args  []string
+	Login bool
+	Env   map[string]string
+	Dir   string
+	User  string
+}
+
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		// powershell has some issues like clearing the screen
+		// return "powershell.exe"
+		return "cmd.exe"
+	}
+
+	shell := os.Getenv("SHELL")
+
+	if shell == "" {
+		shell = "bash"
+	}
+
+	return shell
+}
+
+// resolveShell combina a configuração local com os campos opcionais enviados
+// pelo servidor, rejeitando o que não estiver liberado na allowlist.
+func resolveShell(
+	cfg config.TerminalConfig,
+	payload pubsub.PtySessionStartedPayload,
+) (ShellSpec, error) {
+	spec := ShellSpec{
+		Shell: cfg.Shell,
+		Args:  cfg.Args,
+		Login: cfg.Login,
+		Env:   maps.Clone(cfg.Env),
+		Dir:   cfg.Dir,
+		User:  cfg.User,
+	}
+
+	allow := cfg.Allow
+
+	if payload.Shell != "" {
+		if !slices.Contains(allow.Shells, payload.Shell) {
+			return spec, fmt.Errorf("shell não permitido: %s", payload.Shell)
+		}
+
+		spec.Shell = payload.Shell
+	}
+
+	if payload.Args != nil {
+		if !allow.Args {
+			return spec, fmt.Errorf("argumentos do shell não permitidos")
+		}
+
+		spec.Args = payload.Args
+	}
+
+	if payload.Login != nil {
+		if !allow.Login && *payload.Login != cfg.Login {
+			return spec, fmt.Errorf("login shell não permitido")
+		}
+
+		spec.Login = *payload.Login
+	}
+
+	for key, value := range payload.Env {
+		if !slices.Contains(allow.Env, key) {
+			return spec, fmt.Errorf("variável de ambiente não permitida: %s", key)
+		}
+
+		if spec.Env == nil {
+			spec.Env = make(map[string]string)
+		}
+
+		spec.Env[key] = value
+	}
+
+	if payload.Dir != "" {
+		if !system.PathWithin(allow.Dirs, payload.Dir) {
+			return spec, fmt.Errorf("diretório não permitido: %s", payload.Dir)
+		}
+
+		spec.Dir = payload.Dir
+	}
+
+	if payload.User != "" {
+		if !slices.Contains(allow.Users, payload.User) {
+			return spec, fmt.Errorf("usuário não permitido: %s", payload.User)
+		}
+
+		spec.User = payload.User
+	}
+
+	if spec.Shell == "" {
+		spec.Shell = defaultShell()
+	}
+
+	return spec, nil
+}
+
+func (s ShellSpec) environ() []string {
+	env := make([]string, 0, len(s.Env))
+
+	for _, key := range slices.Sorted(maps.Keys(s.Env)) {
+		env = append(env, key+"="+s.Env[key])
+	}
+
+	return env
+}
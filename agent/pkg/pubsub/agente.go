@@ -1,6 +1,112 @@
 package pubsub
 
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
 type AgenteUpdatedPayload struct {
 	Situacao  string  `json:"situacao"`
 	DeletedAt *string `json:"deletedAt"`
 }
+
+type AgenteRotateCredentialsPayload struct {
+	Motivo string `json:"motivo,omitempty"`
+}
+
+const (
+	InventarioCompleto = "snapshot"
+	InventarioDiff     = "diff"
+)
+
+type AgenteInventoryPayload struct {
+	// snapshot com o inventário completo ou diff apenas com as seções
+	// alteradas desde o último envio
+	Tipo       string                     `json:"tipo"`
+	ColetadoEm time.Time                  `json:"coletadoEm"`
+	Secoes     map[string]json.RawMessage `json:"secoes"`
+}
+
+// Estatísticas de uma métrica no período agregado
+type MetricStat struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+type AgenteMetricsPayload struct {
+	Inicio   time.Time  `json:"inicio"`
+	Fim      time.Time  `json:"fim"`
+	Amostras int        `json:"amostras"`
+	Cpu      MetricStat `json:"cpu"`
+	Memoria  MetricStat `json:"mem"`
+	// Uso em porcentagem por ponto de montagem, na última amostra
+	Discos map[string]float64 `json:"discos"`
+	// Médias de carga de 1, 5 e 15 minutos
+	Load [3]float64 `json:"load"`
+	// Vazão média em bytes por segundo
+	RedeRx    float64 `json:"rx"`
+	RedeTx    float64 `json:"tx"`
+	Processos int     `json:"procs"`
+	// Filas de processamento dos eventos recebidos, por evento
+	Eventos map[string]EventoStat `json:"eventos,omitempty"`
+}
+
+// EventoStat resume a fila de um evento desde o início do agente.
+type EventoStat struct {
+	Fila        int    `json:"fila"`
+	Processados uint64 `json:"processados"`
+	Descartados uint64 `json:"descartados"`
+	// Latência dos handlers em milissegundos
+	LatenciaMedia float64 `json:"latenciaMedia"`
+	LatenciaMax   float64 `json:"latenciaMax"`
+}
+
+const (
+	AlertaAtivo       = "ativo"
+	AlertaNormalizado = "normalizado"
+)
+
+type AgenteAlertPayload struct {
+	// cpu, memoria ou disco
+	Tipo string `json:"tipo"`
+	// Ponto de montagem, no caso de disco
+	Recurso string    `json:"recurso,omitempty"`
+	Estado  string    `json:"estado"`
+	Valor   float64   `json:"valor"`
+	Limite  float64   `json:"limite"`
+	Em      time.Time `json:"em"`
+}
+
+type AgenteUpdatePayload struct {
+	Url    string `json:"url"`
+	Versao string `json:"versao"`
+	// SHA-256 do binário em hexadecimal
+	Sha256 string `json:"sha256"`
+	// Assinatura Ed25519, em base64, de "versao\nGOOS/GOARCH\nsha256"
+	Assinatura string `json:"assinatura"`
+}
+
+const (
+	AtualizacaoConcluida = "concluido"
+	AtualizacaoFalha     = "falha"
+	// A nova versão não conectou ao servidor e o agente voltou para a
+	// versão anterior
+	AtualizacaoRevertida = "revertido"
+)
+
+type AgenteUpdateResultPayload struct {
+	Versao   string `json:"versao"`
+	Anterior string `json:"anterior"`
+	Status   string `json:"status"`
+	Erro     string `json:"erro,omitempty"`
+}
+
+func (p AgenteUpdatePayload) Validate() error {
+	if p.Url == "" || p.Versao == "" || p.Sha256 == "" || p.Assinatura == "" {
+		return errors.New("url, versao, sha256 e assinatura são obrigatórios")
+	}
+
+	return nil
+}
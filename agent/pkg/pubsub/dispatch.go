@@ -0,0 +1,269 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrDispatchFull = errors.New("fila de processamento cheia, mensagem descartada")
+
+type DispatchMode int
+
+const (
+	// Handlers executados em paralelo, limitados por Workers
+	DispatchConcurrent DispatchMode = iota
+	// Um handler por vez, na ordem de chegada (por evento ou por Key)
+	DispatchOrdered
+	// Um handler por vez, descartando as mensagens mais antigas quando a
+	// fila atinge QueueSize
+	DispatchDropOldest
+)
+
+type DispatchPolicy struct {
+	Mode    DispatchMode
+	Workers int
+	// Com a fila cheia, novas mensagens são descartadas e reportadas com
+	// ErrDispatchFull (exceto em DispatchDropOldest)
+	QueueSize int
+	// Usado no modo ordenado para manter uma fila por chave (ex: sessão)
+	Key func(data string) string
+	// Chamado com a mensagem descartada por fila cheia, para eventos em que
+	// o descarte não pode passar despercebido (ex: encerrando a sessão).
+	// Executado na leitura do socket, não deve bloquear
+	OnFull func(ctx context.Context, data string)
+}
+
+var defaultDispatchPolicy = DispatchPolicy{
+	Mode:      DispatchConcurrent,
+	Workers:   16,
+	QueueSize: 256,
+}
+
+// KeyField retorna uma função de chave que extrai o campo informado do
+// payload JSON.
+func KeyField(field string) func(data string) string {
+	return func(data string) string {
+		var fields map[string]json.RawMessage
+
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return ""
+		}
+
+		return string(fields[field])
+	}
+}
+
+type DispatchStats struct {
+	QueueDepth   int
+	Processed    uint64
+	Dropped      uint64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+type dispatchJob struct {
+	ctx     context.Context
+	handler contextHandler
+	data    string
+}
+
+// dispatcher distribui as mensagens de um evento conforme sua política.
+type dispatcher struct {
+	event  string
+	policy DispatchPolicy
+	ps     *PubSub
+
+	mu    sync.Mutex
+	lanes map[string]*lane
+
+	statsMu sync.Mutex
+	stats   DispatchStats
+}
+
+func newDispatcher(ps *PubSub, event string, policy DispatchPolicy) *dispatcher {
+	if policy.QueueSize <= 0 {
+		policy.QueueSize = defaultDispatchPolicy.QueueSize
+	}
+
+	if policy.Mode == DispatchConcurrent && policy.Workers <= 0 {
+		policy.Workers = defaultDispatchPolicy.Workers
+	}
+
+	if policy.Mode != DispatchConcurrent {
+		policy.Workers = 1
+	}
+
+	return &dispatcher{
+		event:  event,
+		policy: policy,
+		ps:     ps,
+		lanes:  make(map[string]*lane),
+	}
+}
+
+func (d *dispatcher) submit(job dispatchJob) {
+	key := ""
+
+	if d.policy.Mode == DispatchOrdered && d.policy.Key != nil {
+		key = d.policy.Key(job.data)
+	}
+
+	d.mu.Lock()
+	l, ok := d.lanes[key]
+
+	if !ok {
+		l = &lane{d: d, key: key}
+		d.lanes[key] = l
+	}
+
+	l.mu.Lock()
+	d.mu.Unlock()
+
+	// A leitura do socket nunca espera por espaço na fila, senão pings e
+	// pongs deixariam de ser processados
+	if !l.push(job) {
+		d.ps.pending.Add(-1)
+
+		d.statsMu.Lock()
+		d.stats.Dropped++
+		d.statsMu.Unlock()
+
+		d.ps.reportError(job.ctx, fmt.Errorf("%w: %s", ErrDispatchFull, d.event))
+
+		if d.policy.OnFull != nil {
+			d.policy.OnFull(job.ctx, job.data)
+		}
+	}
+}
+
+func (d *dispatcher) run(job dispatchJob) {
+	startedAt := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			d.ps.reportError(job.ctx, fmt.Errorf("panic no handler: %v", r))
+		}
+
+		latency := time.Since(startedAt)
+
+		d.ps.pending.Add(-1)
+
+		d.statsMu.Lock()
+		d.stats.Processed++
+		d.stats.TotalLatency += latency
+		d.stats.MaxLatency = max(d.stats.MaxLatency, latency)
+		d.statsMu.Unlock()
+	}()
+
+	job.handler(job.ctx, job.data)
+}
+
+func (d *dispatcher) snapshot() DispatchStats {
+	d.statsMu.Lock()
+	stats := d.stats
+	d.statsMu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, l := range d.lanes {
+		l.mu.Lock()
+		stats.QueueDepth += len(l.queue)
+		l.mu.Unlock()
+	}
+
+	return stats
+}
+
+// lane é uma fila limitada processada por até policy.Workers goroutines,
+// criadas sob demanda e finalizadas quando a fila esvazia.
+type lane struct {
+	d      *dispatcher
+	key    string
+	mu     sync.Mutex
+	queue  []dispatchJob
+	active int
+}
+
+// push deve ser chamado com l.mu travado. Retorna false se a fila estiver
+// cheia e a mensagem tiver sido recusada.
+func (l *lane) push(job dispatchJob) bool {
+	defer l.mu.Unlock()
+
+	policy := l.d.policy
+
+	if len(l.queue) >= policy.QueueSize {
+		if policy.Mode != DispatchDropOldest {
+			return false
+		}
+
+		l.queue = l.queue[1:]
+		l.d.ps.pending.Add(-1)
+
+		l.d.statsMu.Lock()
+		l.d.stats.Dropped++
+		l.d.statsMu.Unlock()
+	}
+
+	l.queue = append(l.queue, job)
+
+	if l.active < policy.Workers {
+		l.active++
+		go l.work()
+	}
+
+	return true
+}
+
+func (l *lane) work() {
+	for {
+		job, ok := l.pop()
+
+		if !ok {
+			return
+		}
+
+		l.d.run(job)
+	}
+}
+
+func (l *lane) pop() (dispatchJob, bool) {
+	l.mu.Lock()
+
+	if len(l.queue) > 0 {
+		job := l.queue[0]
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+
+		return job, true
+	}
+
+	l.mu.Unlock()
+
+	// Revalida com o dispatcher travado para remover a fila da chave sem
+	// concorrer com um novo submit
+	l.d.mu.Lock()
+	l.mu.Lock()
+
+	defer l.d.mu.Unlock()
+	defer l.mu.Unlock()
+
+	if len(l.queue) > 0 {
+		job := l.queue[0]
+		l.queue = l.queue[1:]
+
+		return job, true
+	}
+
+	l.active--
+
+	if l.active == 0 && l.key != "" {
+		delete(l.d.lanes, l.key)
+	}
+
+	return dispatchJob{}, false
+}
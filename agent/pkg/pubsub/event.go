@@ -4,19 +4,52 @@ import "encoding/json"
 
 const (
 	// Subscriptions
-	AgenteUpdatedEvent      = "agente:updated"
-	PtySessionStartedEvent  = "pty:session_started"
-	PtyInputEvent           = "pty:input"
-	ImplantacaoCreatedEvent = "implantacao:created"
+	AgenteUpdatedEvent           = "agente:updated"
+	AgenteRotateCredentialsEvent = "agente:rotate_credentials"
+	AgenteUpdateEvent            = "agente:update"
+	PtySessionStartedEvent       = "pty:session_started"
+	PtyInputEvent                = "pty:input"
+	PtySessionCloseEvent         = "pty:session_close"
+	ImplantacaoCreatedEvent      = "implantacao:created"
+	ExecRequestEvent             = "exec:request"
+	FileGetEvent                 = "file:get"
+	FilePutEvent                 = "file:put"
+	FileListEvent                = "file:list"
+	TunnelOpenEvent              = "tunnel:open"
+	ServicoActionEvent           = "servico:action"
+	// Também publicados pelo agente
+	TunnelDataEvent  = "tunnel:data"
+	TunnelAckEvent   = "tunnel:ack"
+	TunnelCloseEvent = "tunnel:close"
+	RpcRequestEvent  = "rpc:request"
+	RpcResponseEvent = "rpc:response"
+	RpcCancelEvent   = "rpc:cancel"
 	// Publishes
 	PtyOutputEvent       = "pty:output"
 	PtySessionEndedEvent = "pty:session_ended"
+	ExecOutputEvent      = "exec:output"
+	ExecResultEvent      = "exec:result"
+	FileChunkEvent       = "file:chunk"
+	FileAckEvent         = "file:ack"
+	FileEntriesEvent     = "file:entries"
+	TunnelOpenedEvent    = "tunnel:opened"
+	AgenteInventoryEvent = "agente:inventory"
+	AgenteMetricsEvent   = "agente:metrics"
+	ServicoStatusEvent   = "servico:status"
+	// Resultado das ações de start, stop e restart
+	ServicoActionResultEvent = "servico:action_result"
+	// Publicado de forma durável (outbox)
+	ImplantacaoFinishedEvent = "implantacao:finished"
+	AgenteAlertEvent         = "agente:alert"
+	AgenteUpdateResultEvent  = "agente:update_result"
 )
 
 type EventMessage struct {
-	Type  string `json:"type"`
-	Event string `json:"event"`
-	Data  string `json:"data"`
+	ID             string `json:"id,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Type           string `json:"type"`
+	Event          string `json:"event"`
+	Data           string `json:"data"`
 }
 
 type SubscribeToEvent struct {
@@ -55,6 +88,23 @@ func NewEventPublish(event string, data string) []byte {
 	return msg
 }
 
+func NewEventPublishDurable(event string, data string, idempotencyKey string) []byte {
+	eventPublish := EventMessage{
+		IdempotencyKey: idempotencyKey,
+		Type:           "publish",
+		Event:          event,
+		Data:           data,
+	}
+
+	msg, err := json.Marshal(eventPublish)
+
+	if err != nil {
+		return nil
+	}
+
+	return msg
+}
+
 func ParseEventMessage(message []byte) (*EventMessage, error) {
 	var eventMessage EventMessage
 
@@ -0,0 +1,41 @@
+package pubsub
+
+import "errors"
+
+type ExecRequestPayload struct {
+	ID   string   `json:"id"`
+	Argv []string `json:"argv,omitempty"`
+	// Linha de comando executada pelo shell do sistema (sh -c / cmd /C)
+	Shell   string            `json:"shell,omitempty"`
+	Timeout int               `json:"timeout,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Dir     string            `json:"dir,omitempty"`
+	Stream  bool              `json:"stream,omitempty"`
+}
+
+type ExecOutputPayload struct {
+	ID     string `json:"id"`
+	Seq    int64  `json:"seq"`
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+type ExecResultPayload struct {
+	ID        string `json:"id"`
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+	Streamed  bool   `json:"streamed"`
+	Truncated bool   `json:"truncated"`
+	TimedOut  bool   `json:"timedOut"`
+	Duration  int64  `json:"duration"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (p ExecRequestPayload) Validate() error {
+	if p.ID == "" {
+		return errors.New("id é obrigatório")
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+type FileGetPayload struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	// Bytes que o servidor aceita receber sem confirmar com file:ack
+	Window int64 `json:"window"`
+}
+
+type FileChunkPayload struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Data   []byte `json:"data"`
+	EOF    bool   `json:"eof"`
+	// SHA-256 do arquivo completo, enviado junto com o último bloco
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type FilePutPayload struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+	EOF      bool   `json:"eof"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type FileAckPayload struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	// Próximo offset esperado por quem recebe: o agente no file:put e o
+	// servidor no file:get
+	Offset   int64  `json:"offset"`
+	Done     bool   `json:"done"`
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type FileListPayload struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+	Dir     bool      `json:"dir"`
+}
+
+type FileEntriesPayload struct {
+	ID      string      `json:"id"`
+	Path    string      `json:"path"`
+	Entries []FileEntry `json:"entries"`
+	Error   string      `json:"error,omitempty"`
+}
+
+func (p FileGetPayload) Validate() error {
+	if p.ID == "" || p.Path == "" {
+		return errors.New("id e path são obrigatórios")
+	}
+
+	return nil
+}
+
+func (p FilePutPayload) Validate() error {
+	if p.ID == "" || p.Path == "" {
+		return errors.New("id e path são obrigatórios")
+	}
+
+	return nil
+}
+
+func (p FileListPayload) Validate() error {
+	if p.ID == "" {
+		return errors.New("id é obrigatório")
+	}
+
+	return nil
+}
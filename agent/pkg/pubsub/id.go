@@ -0,0 +1,13 @@
+package pubsub
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+func newID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
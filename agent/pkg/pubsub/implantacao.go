@@ -1,8 +1,10 @@
 package pubsub
 
 import (
+	"agent/pkg/proxy"
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,68 +26,149 @@ type Dependency struct {
 }
 
 type ImplantacaoCreatedPayload struct {
-	Url      string   `json:"url"`
-	Manifest Manifest `json:"manifest"`
+	IdImplantacao int      `json:"idImplantacao"`
+	Url           string   `json:"url"`
+	Manifest      Manifest `json:"manifest"`
+}
+
+// Mesmos valores de status usados pela API em implantacao_agente
+const (
+	ImplantacaoConcluida = "concluido"
+	ImplantacaoFalha     = "falha"
+)
+
+type ImplantacaoFinishedPayload struct {
+	IdImplantacao int    `json:"idImplantacao"`
+	Versao        string `json:"versao"`
+	Status        string `json:"status"`
+	Erro          string `json:"erro,omitempty"`
 }
 
 type Manifest struct {
 	Version      string       `json:"version"`
 	Dependencies []Dependency `json:"dependencies"`
+	// Serviços da aplicação implantada, monitorados pelo agente
+	Services []ServiceRef `json:"services"`
 }
 
-func HandleImplantacaoCreated() EventHandler {
-	return func(data string) {
-		var payload ImplantacaoCreatedPayload
+func HandleImplantacaoCreated(ps *PubSub, journal *ImplantacaoJournal) TypedHandler[ImplantacaoCreatedPayload] {
+	return func(ctx context.Context, payload ImplantacaoCreatedPayload) {
+		// Sem id nem versão a implantação não pode ser deduplicada
+		key := JournalKey(payload)
 
-		err := json.Unmarshal([]byte(data), &payload)
+		if key != "" {
+			first, err := journal.Begin(key, payload)
 
-		if err != nil {
-			fmt.Println("Erro ao parsear payload:", err)
-			return
+			if err != nil {
+				fmt.Println("Erro ao registrar implantação:", err)
+			}
+
+			if !first {
+				fmt.Println("Implantação já recebida, ignorando:", key)
+				return
+			}
 		}
 
-		// TODO: Implementar manager para impedir múltiplas implantações simultâneas
+		result := ImplantacaoFinishedPayload{
+			IdImplantacao: payload.IdImplantacao,
+			Versao:        payload.Manifest.Version,
+			Status:        ImplantacaoConcluida,
+		}
 
-		// Steps:
-		// 1. Download do arquivo
-		// 2. Extrair o arquivo para uma pasta temporária
-		// 3. Ler o manifest e validar
-		// 4. Executar os scripts na ordem correta
-		// 5. Limpar a pasta temporária
+		err := runImplantacao(ctx, payload)
 
-		fmt.Println("Iniciando implantação com URL:", payload.Url)
+		// O agente está sendo finalizado: a implantação fica registrada como
+		// interrompida e será executada novamente na próxima sincronização
+		if ctx.Err() != nil {
+			fmt.Println("Implantação interrompida:", key)
 
-		dataBytes, err := downloadUrl(payload.Url)
+			if key != "" {
+				err = journal.Finish(key, ImplantacaoInterrompida, context.Cause(ctx).Error())
+
+				if err != nil {
+					fmt.Println("Erro ao registrar implantação:", err)
+				}
+			}
 
-		if err != nil {
-			fmt.Println("Erro ao baixar o arquivo:", err)
 			return
 		}
 
-		fmt.Println("Arquivo baixado com sucesso, tamanho:", len(dataBytes))
-
-		tempDir, err := extractArchive(dataBytes)
-
 		if err != nil {
-			fmt.Println("Erro ao extrair o arquivo:", err)
-			return
+			result.Status = ImplantacaoFalha
+			result.Erro = err.Error()
 		}
 
-		fmt.Println("Arquivo extraído para:", tempDir)
+		if key != "" {
+			err = journal.Finish(key, result.Status, result.Erro)
 
-		fmt.Println("Manifest lido com sucesso, versão:", payload.Manifest.Version)
+			if err != nil {
+				fmt.Println("Erro ao registrar implantação:", err)
+			}
+		}
 
-		err = executeDependencies(payload.Manifest.Dependencies, tempDir)
+		data, err := json.Marshal(result)
 
 		if err != nil {
-			fmt.Println("Erro ao executar dependências:", err)
+			fmt.Println("Erro ao serializar resultado da implantação:", err)
 			return
 		}
+
+		// O resultado precisa chegar ao servidor mesmo que a conexão caia
+		err = ps.PublishDurable(ImplantacaoFinishedEvent, string(data))
+
+		if err != nil {
+			fmt.Println("Erro ao publicar resultado da implantação:", err)
+		}
 	}
 }
 
-func downloadUrl(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+func runImplantacao(ctx context.Context, payload ImplantacaoCreatedPayload) error {
+	// TODO: Implementar manager para impedir múltiplas implantações simultâneas
+
+	// Steps:
+	// 1. Download do arquivo
+	// 2. Extrair o arquivo para uma pasta temporária
+	// 3. Ler o manifest e validar
+	// 4. Executar os scripts na ordem correta
+	// 5. Limpar a pasta temporária
+
+	fmt.Println("Iniciando implantação com URL:", payload.Url)
+
+	dataBytes, err := downloadUrl(ctx, payload.Url)
+
+	if err != nil {
+		return fmt.Errorf("erro ao baixar o arquivo: %w", err)
+	}
+
+	fmt.Println("Arquivo baixado com sucesso, tamanho:", len(dataBytes))
+
+	tempDir, err := extractArchive(dataBytes)
+
+	if err != nil {
+		return fmt.Errorf("erro ao extrair o arquivo: %w", err)
+	}
+
+	fmt.Println("Arquivo extraído para:", tempDir)
+
+	fmt.Println("Manifest lido com sucesso, versão:", payload.Manifest.Version)
+
+	err = executeDependencies(ctx, payload.Manifest.Dependencies, tempDir)
+
+	if err != nil {
+		return fmt.Errorf("erro ao executar dependências: %w", err)
+	}
+
+	return nil
+}
+
+func downloadUrl(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := proxy.Client().Do(req)
 
 	if err != nil {
 		return nil, err
@@ -174,7 +257,7 @@ func extractArchive(data []byte) (string, error) {
 	return tempDir, nil
 }
 
-func executeDependencies(deps []Dependency, basePath string) error {
+func executeDependencies(ctx context.Context, deps []Dependency, basePath string) error {
 	for _, dep := range deps {
 		depPath := basePath + string(os.PathSeparator) + dep.Path
 
@@ -185,13 +268,13 @@ func executeDependencies(deps []Dependency, basePath string) error {
 		}
 
 		// Executar dependências recursivamente
-		err = executeDependencies(dep.Dependencies, basePath)
+		err = executeDependencies(ctx, dep.Dependencies, basePath)
 
 		if err != nil {
 			return err
 		}
 
-		cmd := exec.Command(depPath)
+		cmd := exec.CommandContext(ctx, depPath)
 
 		// Ler linha por linha da saída padrão para detectar o padrão de "ready"
 		stdoutPipe, err := cmd.StdoutPipe()
@@ -0,0 +1,213 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Status usados apenas no histórico local
+const (
+	ImplantacaoEmAndamento  = "em_andamento"
+	ImplantacaoInterrompida = "interrompido"
+)
+
+type JournalEntry struct {
+	IdImplantacao int          `json:"idImplantacao,omitempty"`
+	Versao        string       `json:"versao"`
+	Status        string       `json:"status"`
+	Erro          string       `json:"erro,omitempty"`
+	Servicos      []ServiceRef `json:"servicos,omitempty"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+}
+
+// ImplantacaoJournal registra em disco as implantações recebidas, evitando
+// que a mesma implantação seja executada mais de uma vez quando chega tanto
+// pelo evento quanto pela sincronização. As entradas são indexadas pela
+// chave retornada por JournalKey.
+type ImplantacaoJournal struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]JournalEntry
+}
+
+// JournalKey retorna a chave de deduplicação da implantação: o id, quando o
+// servidor o envia, ou a versão do manifest. Retorna "" se não houver
+// nenhum dos dois.
+func JournalKey(payload ImplantacaoCreatedPayload) string {
+	if payload.IdImplantacao > 0 {
+		return strconv.Itoa(payload.IdImplantacao)
+	}
+
+	if payload.Manifest.Version != "" {
+		return "versao:" + payload.Manifest.Version
+	}
+
+	return ""
+}
+
+func NewImplantacaoJournal(path string) (*ImplantacaoJournal, error) {
+	j := &ImplantacaoJournal{
+		path:    path,
+		entries: make(map[string]JournalEntry),
+	}
+
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &j.entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, entry := range j.entries {
+		// Históricos antigos guardavam o id apenas na chave
+		if id, err := strconv.Atoi(key); err == nil && entry.IdImplantacao == 0 {
+			entry.IdImplantacao = id
+		}
+
+		// Implantações que estavam em andamento quando o agente parou sem um
+		// encerramento gracioso também precisam ser executadas novamente
+		if entry.Status == ImplantacaoEmAndamento {
+			entry.Status = ImplantacaoInterrompida
+		}
+
+		j.entries[key] = entry
+	}
+
+	return j, nil
+}
+
+// Begin marca a implantação como em andamento. Retorna false se ela já foi
+// registrada anteriormente e não foi interrompida. Sem id, a mesma versão
+// só é ignorada enquanto estiver em andamento ou for a última concluída,
+// permitindo voltar para uma versão implantada antes.
+func (j *ImplantacaoJournal) Begin(key string, payload ImplantacaoCreatedPayload) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if entry, exists := j.entries[key]; exists && entry.Status != ImplantacaoInterrompida {
+		if payload.IdImplantacao > 0 || entry.Status == ImplantacaoEmAndamento {
+			return false, nil
+		}
+
+		if last, _, ok := j.lastConcluded(); ok && last == key {
+			return false, nil
+		}
+	}
+
+	j.entries[key] = JournalEntry{
+		IdImplantacao: payload.IdImplantacao,
+		Versao:        payload.Manifest.Version,
+		Status:        ImplantacaoEmAndamento,
+		Servicos:      payload.Manifest.Services,
+		UpdatedAt:     time.Now(),
+	}
+
+	return true, j.save()
+}
+
+func (j *ImplantacaoJournal) Finish(key string, status string, erro string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := j.entries[key]
+	entry.Status = status
+	entry.Erro = erro
+	entry.UpdatedAt = time.Now()
+
+	j.entries[key] = entry
+
+	return j.save()
+}
+
+func (j *ImplantacaoJournal) Get(key string) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.entries[key]
+
+	return entry, ok
+}
+
+// Entries retorna uma cópia de todas as implantações registradas.
+func (j *ImplantacaoJournal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]JournalEntry, 0, len(j.entries))
+
+	for _, entry := range j.entries {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// LastConcluded retorna a implantação concluída mais recente.
+func (j *ImplantacaoJournal) LastConcluded() (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, last, found := j.lastConcluded()
+
+	return last, found
+}
+
+func (j *ImplantacaoJournal) lastConcluded() (string, JournalEntry, bool) {
+	var (
+		key   string
+		last  JournalEntry
+		found bool
+	)
+
+	for k, entry := range j.entries {
+		if entry.Status != ImplantacaoConcluida {
+			continue
+		}
+
+		if !found || entry.UpdatedAt.After(last.UpdatedAt) {
+			key = k
+			last = entry
+			found = true
+		}
+	}
+
+	return key, last, found
+}
+
+func (j *ImplantacaoJournal) save() error {
+	data, err := json.MarshalIndent(j.entries, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(j.path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type KeepAlive struct {
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+}
+
+var defaultKeepAlive = KeepAlive{
+	PingInterval: 30 * time.Second,
+	PongTimeout:  10 * time.Second,
+}
+
+// SetKeepAlive configura o intervalo dos pings do WebSocket e o tempo
+// máximo sem resposta antes de a conexão ser considerada perdida.
+func (p *PubSub) SetKeepAlive(keepAlive KeepAlive) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if keepAlive.PingInterval <= 0 {
+		keepAlive.PingInterval = defaultKeepAlive.PingInterval
+	}
+
+	if keepAlive.PongTimeout <= 0 {
+		keepAlive.PongTimeout = defaultKeepAlive.PongTimeout
+	}
+
+	p.keepAlive = keepAlive
+}
+
+// readTimeout é o tempo máximo sem receber nada do servidor, nem mesmo um
+// pong. Conexões TCP meio abertas são detectadas por esse prazo.
+func (k KeepAlive) readTimeout() time.Duration {
+	return k.PingInterval + k.PongTimeout
+}
+
+func (p *PubSub) startKeepAlive(conn *websocket.Conn, keepAlive KeepAlive, closed <-chan struct{}) {
+	conn.SetReadDeadline(time.Now().Add(keepAlive.readTimeout()))
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(keepAlive.readTimeout()))
+	})
+
+	go func() {
+		ticker := time.NewTicker(keepAlive.PingInterval)
+
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				// WriteControl pode ser chamado em paralelo com a goroutine de escrita
+				err := conn.WriteControl(
+					websocket.PingMessage,
+					nil,
+					time.Now().Add(writeTimeout),
+				)
+
+				if err != nil {
+					log.Println("Erro ao enviar ping:", err)
+					conn.Close()
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,274 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrOutboxFull = errors.New("outbox cheio")
+
+type outboxEntry struct {
+	Key       string    `json:"key"`
+	Event     string    `json:"event"`
+	Data      string    `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Outbox guarda em disco os eventos duráveis até que sejam enviados ao
+// servidor. Cada evento carrega uma chave de idempotência para que o
+// servidor possa descartar reenvios.
+type Outbox struct {
+	path    string
+	maxSize int64
+	mu      sync.Mutex
+	notify  chan struct{}
+}
+
+func NewOutbox(path string, maxSize int64) (*Outbox, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{
+		path:    path,
+		maxSize: maxSize,
+		notify:  make(chan struct{}, 1),
+	}, nil
+}
+
+func (o *Outbox) append(entry outboxEntry) error {
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	info, err := os.Stat(o.path)
+
+	if err == nil && o.maxSize > 0 && info.Size()+int64(len(line)) > o.maxSize {
+		return ErrOutboxFull
+	}
+
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(line)
+
+	if err != nil {
+		return err
+	}
+
+	err = file.Sync()
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (o *Outbox) entries() ([]outboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data, err := os.ReadFile(o.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []outboxEntry
+
+	// Sem limite de tamanho por linha: uma entrada grande não pode impedir
+	// a leitura das demais
+	for line := range bytes.Lines(data) {
+		var entry outboxEntry
+
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Println("Descartando entrada inválida do outbox:", err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// remove descarta as entradas já enviadas, mantendo as que foram
+// adicionadas depois da leitura.
+func (o *Outbox) remove(sent []outboxEntry) error {
+	if len(sent) == 0 {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data, err := os.ReadFile(o.path)
+
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool, len(sent))
+
+	for _, entry := range sent {
+		keys[entry.Key] = true
+	}
+
+	var remaining bytes.Buffer
+
+	for line := range bytes.Lines(data) {
+		var entry outboxEntry
+
+		if err := json.Unmarshal(line, &entry); err != nil || keys[entry.Key] {
+			continue
+		}
+
+		remaining.Write(line)
+	}
+
+	tmp := o.path + ".tmp"
+
+	err = os.WriteFile(tmp, remaining.Bytes(), 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, o.path)
+}
+
+// SetOutbox habilita o armazenamento dos eventos publicados com
+// PublishDurable enquanto o agente estiver desconectado.
+func (p *PubSub) SetOutbox(outbox *Outbox) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.outbox = outbox
+}
+
+// PublishDurable grava o evento no outbox antes de enviá-lo, garantindo a
+// entrega (em ordem) mesmo que a conexão esteja indisponível.
+func (p *PubSub) PublishDurable(event string, data string) error {
+	p.mu.RLock()
+	outbox := p.outbox
+	p.mu.RUnlock()
+
+	if outbox == nil {
+		return p.Publish(event, data)
+	}
+
+	return outbox.append(outboxEntry{
+		Key:       newID(),
+		Event:     event,
+		Data:      data,
+		CreatedAt: time.Now(),
+	})
+}
+
+// flushOutbox envia os eventos pendentes enquanto a conexão estiver ativa.
+// Cada entrada só sai do disco depois que a sua própria mensagem foi escrita
+// no socket.
+func (p *PubSub) flushOutbox(ctx context.Context, outbox *Outbox) {
+	for {
+		entries, err := outbox.entries()
+
+		if err != nil {
+			log.Println("Erro ao ler outbox:", err)
+		}
+
+		ok := p.sendOutbox(ctx, outbox, entries)
+
+		if !ok {
+			return
+		}
+
+		select {
+		case <-outbox.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendOutbox enfileira as entradas e remove do outbox as que foram escritas
+// com sucesso. Retorna false se o envio foi interrompido.
+func (p *PubSub) sendOutbox(ctx context.Context, outbox *Outbox, entries []outboxEntry) bool {
+	results := make([]<-chan error, 0, len(entries))
+
+	// Servidores sem a capacidade não conhecem a chave de idempotência
+	keyed := p.Protocol().Has(CapabilityOutbox)
+
+	for _, entry := range entries {
+		msg := NewEventPublish(entry.Event, entry.Data)
+
+		if keyed {
+			msg = NewEventPublishDurable(entry.Event, entry.Data, entry.Key)
+		}
+
+		// Mesma prioridade para todos, preservando a ordem de gravação
+		written, err := p.queue.pushTracked(ctx, ctx.Done(), PriorityNormal, msg)
+
+		if err != nil {
+			break
+		}
+
+		results = append(results, written)
+	}
+
+	sent := make([]outboxEntry, 0, len(results))
+	ok := len(results) == len(entries)
+
+	for i, written := range results {
+		var err error
+
+		select {
+		case err = <-written:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		if err != nil {
+			ok = false
+			break
+		}
+
+		sent = append(sent, entries[i])
+	}
+
+	if err := outbox.remove(sent); err != nil {
+		log.Println("Erro ao atualizar outbox:", err)
+	}
+
+	return ok
+}
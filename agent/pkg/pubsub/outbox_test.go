@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Entradas maiores que o buffer padrão do bufio.Scanner (64KB) não podem
+// impedir a leitura do outbox
+func TestOutboxLargeEntry(t *testing.T) {
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), "outbox.jsonl"), 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := strings.Repeat("x", 256<<10)
+
+	for _, entry := range []outboxEntry{
+		{Key: "1", Event: "evento", Data: "antes", CreatedAt: time.Now()},
+		{Key: "2", Event: "evento", Data: large, CreatedAt: time.Now()},
+		{Key: "3", Event: "evento", Data: "depois", CreatedAt: time.Now()},
+	} {
+		err := outbox.append(entry)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := outbox.entries()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("esperava 3 entradas, obteve %d", len(entries))
+	}
+
+	if entries[1].Data != large || entries[2].Data != "depois" {
+		t.Fatal("entradas lidas não correspondem às gravadas")
+	}
+
+	err = outbox.remove(entries[:2])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = outbox.entries()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Key != "3" {
+		t.Fatalf("esperava apenas a entrada 3, obteve %+v", entries)
+	}
+}
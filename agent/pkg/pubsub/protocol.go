@@ -0,0 +1,107 @@
+package pubsub
+
+import (
+	"agent/pkg/version"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Enviado pelo agente com as versões suportadas e respondido pelo
+	// servidor com a versão escolhida
+	ProtocolHeader = "X-Agente-Protocolo"
+	// Enviado pelo agente com as suas capacidades e respondido pelo
+	// servidor com as capacidades que ele reconhece
+	CapabilitiesHeader = "X-Agente-Capacidades"
+	// Versão do binário do agente
+	VersionHeader = "X-Agente-Versao"
+)
+
+// Versões do protocolo suportadas pelo agente, da mais nova para a mais
+// antiga. Servidores que não respondem o cabeçalho usam a versão 1.
+var supportedVersions = []int{1}
+
+// Capacidades cujo uso pelo agente depende do servidor reconhecê-las
+const (
+	CapabilityRpc         = "rpc"
+	CapabilityOutbox      = "outbox"
+	CapabilitySincronizar = "sincronizar"
+	CapabilityUpdate      = "update"
+	// pty:session_ended com código de saída e motivo em vez do id do agente
+	CapabilityPtyEnded = "pty-ended"
+)
+
+// Capacidades anunciadas ao servidor na conexão
+var capabilities = []string{
+	"pty",
+	"exec",
+	"file",
+	"tunnel",
+	CapabilityRpc,
+	CapabilityOutbox,
+	CapabilitySincronizar,
+	CapabilityUpdate,
+	CapabilityPtyEnded,
+}
+
+var (
+	ErrProtocolUnsupported   = errors.New("versão do protocolo não suportada")
+	ErrCapabilityUnsupported = errors.New("capacidade não reconhecida pelo servidor")
+)
+
+type Protocol struct {
+	Version      int
+	Capabilities []string
+}
+
+// Has informa se o servidor reconhece a capacidade.
+func (p Protocol) Has(capability string) bool {
+	return slices.Contains(p.Capabilities, capability)
+}
+
+func protocolHeaders(header http.Header) {
+	versions := make([]string, len(supportedVersions))
+
+	for i, version := range supportedVersions {
+		versions[i] = strconv.Itoa(version)
+	}
+
+	header.Set(ProtocolHeader, strings.Join(versions, ","))
+	header.Set(CapabilitiesHeader, strings.Join(capabilities, ","))
+	header.Set(VersionHeader, version.Version)
+}
+
+// negotiate lê a versão escolhida pelo servidor na resposta do handshake.
+func negotiate(resp *http.Response) (Protocol, error) {
+	protocol := Protocol{Version: 1}
+
+	if resp == nil {
+		return protocol, nil
+	}
+
+	if value := resp.Header.Get(ProtocolHeader); value != "" {
+		version, err := strconv.Atoi(strings.TrimSpace(value))
+
+		if err != nil {
+			return protocol, fmt.Errorf("%w: %s", ErrProtocolUnsupported, value)
+		}
+
+		protocol.Version = version
+	}
+
+	if !slices.Contains(supportedVersions, protocol.Version) {
+		return protocol, fmt.Errorf("%w: %d", ErrProtocolUnsupported, protocol.Version)
+	}
+
+	for _, capability := range strings.Split(resp.Header.Get(CapabilitiesHeader), ",") {
+		if capability = strings.TrimSpace(capability); capability != "" {
+			protocol.Capabilities = append(protocol.Capabilities, capability)
+		}
+	}
+
+	return protocol, nil
+}
@@ -1,5 +1,7 @@
 package pubsub
 
+import "errors"
+
 type PtyOutputPayload struct {
 	SessionID string `json:"session_id"`
 	Output    []byte `json:"output"`
@@ -11,5 +13,46 @@ type PtyInputPayload struct {
 }
 
 type PtySessionStartedPayload struct {
-	IdAgente int `json:"idAgente"`
+	IdAgente int               `json:"idAgente"`
+	Shell    string            `json:"shell,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Login    *bool             `json:"login,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Dir      string            `json:"dir,omitempty"`
+	User     string            `json:"user,omitempty"`
+}
+
+type PtySessionClosePayload struct {
+	IdAgente int    `json:"idAgente"`
+	Reason   string `json:"reason"`
+}
+
+type PtySessionEndedPayload struct {
+	IdAgente int    `json:"idAgente"`
+	ExitCode int    `json:"exitCode"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (p PtyInputPayload) Validate() error {
+	if p.IdAgente <= 0 {
+		return errors.New("idAgente é obrigatório")
+	}
+
+	return nil
+}
+
+func (p PtySessionStartedPayload) Validate() error {
+	if p.IdAgente <= 0 {
+		return errors.New("idAgente é obrigatório")
+	}
+
+	return nil
+}
+
+func (p PtySessionClosePayload) Validate() error {
+	if p.IdAgente <= 0 {
+		return errors.New("idAgente é obrigatório")
+	}
+
+	return nil
 }
@@ -1,11 +1,15 @@
 package pubsub
 
 import (
-	"agent/pkg/secret"
+	"agent/pkg/identity"
+	"agent/pkg/proxy"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -13,22 +17,133 @@ import (
 
 type EventHandler func(data string)
 
+type contextHandler func(ctx context.Context, data string)
+
+const defaultUrl = "ws://localhost:3000/pubsub/agente"
+
 type PubSub struct {
 	SubscribedEvents []string
-	handlers         map[string][]EventHandler
+	url              string
+	handlers         map[string][]contextHandler
 	conn             *websocket.Conn
 	mu               sync.RWMutex
 	connected        bool
+	onError          ErrorHandler
+	policies         map[string]DispatchPolicy
+	dispatchers      map[string]*dispatcher
+	queue            *sendQueue
+	outbox           *Outbox
+	onConnect        []func(ctx context.Context)
+	onDisconnect     []func()
+	keepAlive        KeepAlive
+	protocol         Protocol
+	// Handlers enfileirados ou em execução
+	pending atomic.Int64
+	// Fechado quando a conexão atual termina
+	closed chan struct{}
 }
 
 func New(events []string) *PubSub {
 	return &PubSub{
 		SubscribedEvents: events,
-		handlers:         make(map[string][]EventHandler),
+		url:              defaultUrl,
+		handlers:         make(map[string][]contextHandler),
+		policies:         make(map[string]DispatchPolicy),
+		dispatchers:      make(map[string]*dispatcher),
+		queue:            newSendQueue(),
+		keepAlive:        defaultKeepAlive,
+		onError: func(meta EventMetadata, err error) {
+			log.Printf("Erro no evento %s (%s): %v", meta.Event, meta.MessageID, err)
+		},
+	}
+}
+
+// SetUrl define o endereço WebSocket usado nas próximas conexões.
+func (p *PubSub) SetUrl(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.url = url
+}
+
+// SetErrorHandler define o hook central chamado quando um evento não pode
+// ser processado.
+func (p *PubSub) SetErrorHandler(handler ErrorHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onError = handler
+}
+
+func (p *PubSub) reportError(ctx context.Context, err error) {
+	meta, _ := MetadataFrom(ctx)
+
+	p.mu.RLock()
+	onError := p.onError
+	p.mu.RUnlock()
+
+	if onError != nil {
+		onError(meta, err)
+	}
+}
+
+// SetDispatchPolicy define como os handlers do evento são executados. Deve
+// ser chamado antes do primeiro evento ser recebido.
+func (p *PubSub) SetDispatchPolicy(event string, policy DispatchPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.policies[event] = policy
+	delete(p.dispatchers, event)
+}
+
+// DispatchStats retorna as métricas de fila e latência de cada evento.
+func (p *PubSub) DispatchStats() map[string]DispatchStats {
+	p.mu.RLock()
+	dispatchers := make(map[string]*dispatcher, len(p.dispatchers))
+
+	for event, d := range p.dispatchers {
+		dispatchers[event] = d
+	}
+
+	p.mu.RUnlock()
+
+	stats := make(map[string]DispatchStats, len(dispatchers))
+
+	for event, d := range dispatchers {
+		stats[event] = d.snapshot()
+	}
+
+	return stats
+}
+
+func (p *PubSub) dispatcher(event string) *dispatcher {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	d, ok := p.dispatchers[event]
+
+	if !ok {
+		policy, ok := p.policies[event]
+
+		if !ok {
+			policy = defaultDispatchPolicy
+		}
+
+		d = newDispatcher(p, event, policy)
+		p.dispatchers[event] = d
 	}
+
+	return d
 }
 
 func (p *PubSub) Subscribe(event string, handler EventHandler) {
+	p.subscribe(event, func(ctx context.Context, data string) {
+		handler(data)
+	})
+}
+
+func (p *PubSub) subscribe(event string, handler contextHandler) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -37,66 +152,136 @@ func (p *PubSub) Subscribe(event string, handler EventHandler) {
 	if p.connected && p.conn != nil {
 		eventSubscription := NewEventSubscription(event)
 
-		if err := p.conn.WriteMessage(websocket.TextMessage, eventSubscription); err != nil {
+		if err := p.queue.push(PriorityControl, eventSubscription); err != nil {
 			log.Printf("Erro ao enviar mensagem de inscrição: %v", err)
 		}
 	}
 }
 
-func (p *PubSub) Connect() error {
-	dialer := websocket.Dialer{}
+// Connect conecta ao servidor e processa mensagens até a conexão cair. O
+// contexto é repassado para os handlers dos eventos.
+func (p *PubSub) Connect(ctx context.Context) error {
+	p.mu.RLock()
+	url := p.url
+	p.mu.RUnlock()
 
-	token, err := secret.Get()
+	scheme, _, _ := strings.Cut(url, "://")
+
+	dialer := websocket.Dialer{
+		NetDialContext:    proxy.DialContext(scheme),
+		HandshakeTimeout:  45 * time.Second,
+		EnableCompression: true,
+	}
+
+	requestHeader := http.Header{}
+
+	err := identity.Authorize(requestHeader)
 
 	if err != nil {
 		return err
 	}
 
-	url := "ws://localhost:3000/pubsub/agente"
-	requestHeader := http.Header{}
-	requestHeader.Add("X-Agente-Token", token)
+	protocolHeaders(requestHeader)
 
-	conn, resp, err := dialer.Dial(url, requestHeader)
+	conn, resp, err := dialer.DialContext(ctx, url, requestHeader)
 
 	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
 		if resp != nil {
-			log.Printf("Código HTTP: %d", resp.StatusCode)
-			log.Fatalf("Erro ao conectar ao WebSocket: %s (código HTTP: %d)", err, resp.StatusCode)
+			if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				return fmt.Errorf("%w (código HTTP: %d)", identity.ErrRevoked, resp.StatusCode)
+			}
+
+			return fmt.Errorf("erro ao conectar ao WebSocket: %w (código HTTP: %d)", err, resp.StatusCode)
 		}
 
-		log.Fatalf("Erro ao conectar ao WebSocket: %s", err)
+		return fmt.Errorf("erro ao conectar ao WebSocket: %w", err)
+	}
+
+	protocol, err := negotiate(resp)
+
+	if err != nil {
+		conn.Close()
+		return err
 	}
 
+	log.Printf("Conectado usando o protocolo v%d", protocol.Version)
+
+	closed := make(chan struct{})
+
+	p.queue.clear()
+
 	p.mu.Lock()
+	p.protocol = protocol
 	p.conn = conn
 	p.connected = true
+	p.closed = closed
+	keepAlive := p.keepAlive
 	p.mu.Unlock()
 
+	go p.writeLoop(conn, closed)
+
 	for _, event := range p.SubscribedEvents {
 		eventSubscription := NewEventSubscription(event)
 
-		err := conn.WriteMessage(websocket.TextMessage, eventSubscription)
+		err := p.queue.push(PriorityControl, eventSubscription)
 
 		if err != nil {
 			log.Println("Erro ao enviar mensagem de inscrição:", err)
+			conn.Close()
+			close(closed)
+			p.disconnect()
 			return err
 		}
 	}
 
 	done := make(chan struct{})
 
+	connCtx, cancelConn := context.WithCancel(ctx)
+	defer cancelConn()
+
+	p.mu.RLock()
+	outbox := p.outbox
+	p.mu.RUnlock()
+
+	if outbox != nil {
+		go p.flushOutbox(connCtx, outbox)
+	}
+
+	p.mu.RLock()
+	onConnect := p.onConnect
+	p.mu.RUnlock()
+
+	for _, hook := range onConnect {
+		go hook(connCtx)
+	}
+
 	go func() {
 		defer close(done)
+		defer cancelConn()
+		defer close(closed)
 		defer conn.Close()
 
+		p.startKeepAlive(conn, keepAlive, closed)
+
 		for {
 			_, message, err := conn.ReadMessage()
 
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				log.Println("Conexão encerrada")
+				return
+			}
+
 			if err != nil {
 				log.Println("Erro ao ler mensagem:", err)
 				return
 			}
 
+			conn.SetReadDeadline(time.Now().Add(keepAlive.readTimeout()))
+
 			parsed, err := ParseEventMessage(message)
 
 			if err != nil {
@@ -108,7 +293,7 @@ func (p *PubSub) Connect() error {
 				continue
 			}
 
-			p.dispatch(parsed.Event, parsed.Data)
+			p.dispatch(ctx, parsed)
 		}
 	}()
 
@@ -120,9 +305,8 @@ func (p *PubSub) Connect() error {
 		for {
 			select {
 			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.TextMessage, Heartbeat()); err != nil {
-					log.Println("Erro ao enviar ping:", err)
-					return
+				if err := p.queue.push(PriorityControl, Heartbeat()); err != nil {
+					log.Println("Erro ao enviar heartbeat:", err)
 				}
 			case <-done:
 				return
@@ -132,41 +316,214 @@ func (p *PubSub) Connect() error {
 
 	<-done
 
+	p.disconnect()
+
+	p.mu.RLock()
+	onDisconnect := p.onDisconnect
+	p.mu.RUnlock()
+
+	for _, hook := range onDisconnect {
+		hook()
+	}
+
+	return nil
+}
+
+// Protocol retorna a versão e as capacidades negociadas na última conexão.
+func (p *PubSub) Protocol() Protocol {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.protocol
+}
+
+// OnConnect registra uma função executada a cada conexão bem-sucedida. O
+// contexto é cancelado quando a conexão termina.
+func (p *PubSub) OnConnect(hook func(ctx context.Context)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onConnect = append(p.onConnect, hook)
+}
+
+// OnDisconnect registra uma função executada quando uma conexão
+// estabelecida termina, antes da próxima tentativa de conexão.
+func (p *PubSub) OnDisconnect(hook func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onDisconnect = append(p.onDisconnect, hook)
+}
+
+// Deliver entrega um evento aos handlers locais como se tivesse sido
+// recebido pelo socket.
+func (p *PubSub) Deliver(ctx context.Context, event string, data string) {
+	p.dispatch(ctx, &EventMessage{
+		Type:  "event",
+		Event: event,
+		Data:  data,
+	})
+}
+
+func (p *PubSub) disconnect() {
 	p.mu.Lock()
 	p.connected = false
 	p.conn = nil
 	p.mu.Unlock()
+}
 
-	return nil
+// writeLoop é o único responsável por escrever no socket, já que a conexão
+// do gorilla/websocket não suporta escritas concorrentes.
+func (p *PubSub) writeLoop(conn *websocket.Conn, closed <-chan struct{}) {
+	for {
+		out, ok := p.queue.next(closed)
+
+		if !ok {
+			return
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+		err := conn.WriteMessage(websocket.TextMessage, out.msg)
+
+		if out.written != nil {
+			out.written <- err
+		}
+
+		p.queue.add(-1)
+
+		if err != nil {
+			log.Println("Erro ao escrever mensagem:", err)
+			conn.Close()
+			return
+		}
+	}
 }
 
-func (p *PubSub) dispatch(event string, data string) {
+func (p *PubSub) dispatch(ctx context.Context, message *EventMessage) {
+	// Durante o encerramento do agente novos eventos são ignorados
+	if ctx.Err() != nil {
+		return
+	}
+
+	event := message.Event
+
 	p.mu.RLock()
 	handlers := p.handlers[event]
 	p.mu.RUnlock()
 
+	meta := EventMetadata{
+		MessageID:  message.ID,
+		Event:      event,
+		ReceivedAt: time.Now(),
+	}
+
+	if meta.MessageID == "" {
+		meta.MessageID = newID()
+	}
+
+	ctx = withMetadata(ctx, meta)
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	d := p.dispatcher(event)
+
 	for _, handler := range handlers {
-		go func(h EventHandler) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("Recovered in handler for event %s: %v", event, r)
-				}
-			}()
-			h(data)
-		}(handler)
+		p.pending.Add(1)
+		d.submit(dispatchJob{
+			ctx:     ctx,
+			handler: handler,
+			data:    message.Data,
+		})
 	}
 }
 
+// Publish enfileira o evento para envio sem bloquear. Retorna ErrQueueFull
+// se a fila da prioridade do evento estiver cheia.
 func (p *PubSub) Publish(event string, data string) error {
 	p.mu.RLock()
-	conn := p.conn
 	connected := p.connected
 	p.mu.RUnlock()
 
-	if !connected || conn == nil {
-		return fmt.Errorf("not connected")
+	if !connected {
+		return ErrNotConnected
+	}
+
+	return p.queue.push(EventPriority(event), NewEventPublish(event, data))
+}
+
+// PublishWait enfileira o evento aguardando espaço na fila. Deve ser usado
+// por quem produz dados em volume e precisa de backpressure.
+func (p *PubSub) PublishWait(ctx context.Context, event string, data string) error {
+	p.mu.RLock()
+	connected := p.connected
+	closed := p.closed
+	p.mu.RUnlock()
+
+	if !connected {
+		return ErrNotConnected
+	}
+
+	return p.queue.pushWait(ctx, closed, EventPriority(event), NewEventPublish(event, data))
+}
+
+// Flush aguarda até que a fila de envio esteja vazia.
+func (p *PubSub) Flush(ctx context.Context) error {
+	return p.queue.flush(ctx)
+}
+
+// QueueLen retorna a quantidade de mensagens aguardando envio.
+func (p *PubSub) QueueLen() int {
+	return p.queue.Len()
+}
+
+// Drain aguarda até que todos os handlers em execução ou enfileirados
+// terminem.
+func (p *PubSub) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for p.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Close envia o frame de encerramento ao servidor e aguarda o fim da
+// conexão. Se o contexto for cancelado antes, a conexão é fechada.
+func (p *PubSub) Close(ctx context.Context, reason string) error {
+	p.mu.RLock()
+	conn := p.conn
+	closed := p.closed
+	p.mu.RUnlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	err := conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
+		time.Now().Add(writeTimeout),
+	)
+
+	if err != nil {
+		conn.Close()
+	}
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		conn.Close()
+		<-closed
 	}
 
-	publishMsg := NewEventPublish(event, data)
-	return conn.WriteMessage(websocket.TextMessage, publishMsg)
+	return err
 }
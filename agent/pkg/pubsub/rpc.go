@@ -0,0 +1,290 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Tempo limite usado quando o contexto da chamada não possui deadline
+const defaultRpcTimeout = 30 * time.Second
+
+const (
+	RpcErrorMethodNotFound = "method_not_found"
+	RpcErrorInvalidParams  = "invalid_params"
+	RpcErrorInternal       = "internal"
+	RpcErrorCanceled       = "canceled"
+	RpcErrorTimeout        = "timeout"
+)
+
+type RpcRequestPayload struct {
+	ID     string          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	// Deadline em milissegundos desde a época Unix
+	Deadline int64 `json:"deadline,omitempty"`
+}
+
+func (p RpcRequestPayload) Validate() error {
+	if p.ID == "" || p.Method == "" {
+		return errors.New("id e method são obrigatórios")
+	}
+
+	return nil
+}
+
+type RpcResponsePayload struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RpcError       `json:"error,omitempty"`
+}
+
+type RpcCancelPayload struct {
+	ID string `json:"id"`
+}
+
+type RpcError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+type rpcMethod func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Rpc implementa chamadas com resposta sobre o PubSub, nos dois sentidos:
+// o servidor chama métodos registrados no agente e o agente chama métodos
+// do servidor com Call.
+type Rpc struct {
+	ps      *PubSub
+	methods map[string]rpcMethod
+	pending map[string]chan RpcResponsePayload
+	running map[string]context.CancelFunc
+	mu      sync.Mutex
+}
+
+func NewRpc(ps *PubSub) *Rpc {
+	r := &Rpc{
+		ps:      ps,
+		methods: make(map[string]rpcMethod),
+		pending: make(map[string]chan RpcResponsePayload),
+		running: make(map[string]context.CancelFunc),
+	}
+
+	Register(r, "ping", func(ctx context.Context, req struct{}) (time.Time, error) {
+		return time.Now(), nil
+	})
+
+	return r
+}
+
+// Register expõe um método que pode ser chamado pelo servidor.
+func Register[Req any, Resp any](
+	r *Rpc,
+	method string,
+	handler func(ctx context.Context, req Req) (Resp, error),
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.methods[method] = func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req Req
+
+		if len(params) > 0 {
+			err := json.Unmarshal(params, &req)
+
+			if err != nil {
+				return nil, &RpcError{Code: RpcErrorInvalidParams, Message: err.Error()}
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Call chama um método do servidor e aguarda a resposta até o deadline do
+// contexto. Se o contexto for cancelado, o servidor é avisado.
+func Call[Req any, Resp any](ctx context.Context, r *Rpc, method string, req Req) (Resp, error) {
+	var resp Resp
+
+	if !r.ps.Protocol().Has(CapabilityRpc) {
+		return resp, fmt.Errorf("%w: %s", ErrCapabilityUnsupported, CapabilityRpc)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRpcTimeout)
+		defer cancel()
+	}
+
+	params, err := json.Marshal(req)
+
+	if err != nil {
+		return resp, err
+	}
+
+	deadline, _ := ctx.Deadline()
+
+	request := RpcRequestPayload{
+		ID:       newID(),
+		Method:   method,
+		Params:   params,
+		Deadline: deadline.UnixMilli(),
+	}
+
+	responseChan := make(chan RpcResponsePayload, 1)
+
+	r.mu.Lock()
+	r.pending[request.ID] = responseChan
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, request.ID)
+		r.mu.Unlock()
+	}()
+
+	err = r.publish(RpcRequestEvent, request)
+
+	if err != nil {
+		return resp, err
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.Error != nil {
+			return resp, response.Error
+		}
+
+		if len(response.Result) > 0 {
+			err = json.Unmarshal(response.Result, &resp)
+		}
+
+		return resp, err
+	case <-ctx.Done():
+		r.publish(RpcCancelEvent, RpcCancelPayload{ID: request.ID})
+
+		return resp, ctx.Err()
+	}
+}
+
+func (r *Rpc) HandleRequest(ctx context.Context, request RpcRequestPayload) {
+	response := r.serve(ctx, request)
+
+	err := r.publish(RpcResponseEvent, response)
+
+	if err != nil {
+		log.Println("Erro ao publicar resposta rpc:", err)
+	}
+}
+
+func (r *Rpc) serve(ctx context.Context, request RpcRequestPayload) RpcResponsePayload {
+	response := RpcResponsePayload{ID: request.ID}
+
+	r.mu.Lock()
+	method, ok := r.methods[request.Method]
+	r.mu.Unlock()
+
+	if !ok {
+		response.Error = &RpcError{
+			Code:    RpcErrorMethodNotFound,
+			Message: "método não encontrado: " + request.Method,
+		}
+		return response
+	}
+
+	var cancel context.CancelFunc
+
+	if request.Deadline > 0 {
+		ctx, cancel = context.WithDeadline(ctx, time.UnixMilli(request.Deadline))
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	defer cancel()
+
+	r.mu.Lock()
+	r.running[request.ID] = cancel
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, request.ID)
+		r.mu.Unlock()
+	}()
+
+	result, err := method(ctx, request.Params)
+
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		response.Error = toRpcError(err)
+		return response
+	}
+
+	response.Result, err = json.Marshal(result)
+
+	if err != nil {
+		response.Error = toRpcError(err)
+	}
+
+	return response
+}
+
+func (r *Rpc) HandleResponse(ctx context.Context, response RpcResponsePayload) {
+	r.mu.Lock()
+	responseChan, ok := r.pending[response.ID]
+	r.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case responseChan <- response:
+	default:
+	}
+}
+
+func (r *Rpc) HandleCancel(ctx context.Context, payload RpcCancelPayload) {
+	r.mu.Lock()
+	cancel, ok := r.running[payload.ID]
+	r.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+func (r *Rpc) publish(event string, payload any) error {
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	return r.ps.Publish(event, string(data))
+}
+
+func toRpcError(err error) *RpcError {
+	var rpcErr *RpcError
+
+	switch {
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return &RpcError{Code: RpcErrorTimeout, Message: err.Error()}
+	case errors.Is(err, context.Canceled):
+		return &RpcError{Code: RpcErrorCanceled, Message: err.Error()}
+	default:
+		return &RpcError{Code: RpcErrorInternal, Message: err.Error()}
+	}
+}
@@ -0,0 +1,276 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type somaRequest struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// testRpc retorna um Rpc sobre um PubSub marcado como conectado, sem
+// socket. next retorna a próxima mensagem publicada, lida direto da fila
+// de envio.
+func testRpc(t *testing.T, capabilities ...string) (*Rpc, func() *EventMessage) {
+	t.Helper()
+
+	ps := New(nil)
+	ps.connected = true
+	ps.protocol = Protocol{Version: 1, Capabilities: capabilities}
+
+	next := func() *EventMessage {
+		t.Helper()
+
+		timeout := make(chan struct{})
+		timer := time.AfterFunc(time.Second, func() { close(timeout) })
+		defer timer.Stop()
+
+		out, ok := ps.queue.next(timeout)
+
+		if !ok {
+			t.Fatal("nenhuma mensagem publicada")
+		}
+
+		ps.queue.add(-1)
+
+		msg, err := ParseEventMessage(out.msg)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return msg
+	}
+
+	return NewRpc(ps), next
+}
+
+func decode[T any](t *testing.T, msg *EventMessage, event string) T {
+	t.Helper()
+
+	if msg.Event != event {
+		t.Fatalf("esperava o evento %s, obteve %s", event, msg.Event)
+	}
+
+	var payload T
+
+	err := json.Unmarshal([]byte(msg.Data), &payload)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+func TestRpcRegister(t *testing.T) {
+	r, next := testRpc(t)
+
+	Register(r, "soma", func(ctx context.Context, req somaRequest) (int, error) {
+		return req.A + req.B, nil
+	})
+
+	r.HandleRequest(context.Background(), RpcRequestPayload{
+		ID:     "1",
+		Method: "soma",
+		Params: json.RawMessage(`{"a":2,"b":3}`),
+	})
+
+	response := decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	if response.ID != "1" || response.Error != nil || string(response.Result) != "5" {
+		t.Fatalf("resposta inesperada: %+v", response)
+	}
+
+	r.HandleRequest(context.Background(), RpcRequestPayload{
+		ID:     "2",
+		Method: "soma",
+		Params: json.RawMessage(`{"a":"x"}`),
+	})
+
+	response = decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	if response.Error == nil || response.Error.Code != RpcErrorInvalidParams {
+		t.Fatalf("esperava invalid_params, obteve %+v", response)
+	}
+}
+
+func TestRpcMethodNotFound(t *testing.T) {
+	r, next := testRpc(t)
+
+	r.HandleRequest(context.Background(), RpcRequestPayload{ID: "1", Method: "inexistente"})
+
+	response := decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	if response.Error == nil || response.Error.Code != RpcErrorMethodNotFound {
+		t.Fatalf("esperava method_not_found, obteve %+v", response)
+	}
+}
+
+func TestRpcDeadline(t *testing.T) {
+	r, next := testRpc(t)
+
+	Register(r, "deadline", func(ctx context.Context, req struct{}) (int64, error) {
+		deadline, ok := ctx.Deadline()
+
+		if !ok {
+			return 0, errors.New("sem deadline")
+		}
+
+		return deadline.UnixMilli(), nil
+	})
+
+	Register(r, "lento", func(ctx context.Context, req struct{}) (struct{}, error) {
+		<-ctx.Done()
+		return struct{}{}, nil
+	})
+
+	deadline := time.Now().Add(time.Minute).UnixMilli()
+
+	r.HandleRequest(context.Background(), RpcRequestPayload{ID: "1", Method: "deadline", Deadline: deadline})
+
+	response := decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	var got int64
+
+	if response.Error != nil || json.Unmarshal(response.Result, &got) != nil || got != deadline {
+		t.Fatalf("deadline não propagado: %+v", response)
+	}
+
+	r.HandleRequest(context.Background(), RpcRequestPayload{
+		ID:       "2",
+		Method:   "lento",
+		Deadline: time.Now().Add(50 * time.Millisecond).UnixMilli(),
+	})
+
+	response = decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	if response.Error == nil || response.Error.Code != RpcErrorTimeout {
+		t.Fatalf("esperava timeout, obteve %+v", response)
+	}
+}
+
+func TestRpcCancel(t *testing.T) {
+	r, next := testRpc(t)
+
+	started := make(chan struct{})
+
+	Register(r, "lento", func(ctx context.Context, req struct{}) (struct{}, error) {
+		close(started)
+		<-ctx.Done()
+		return struct{}{}, nil
+	})
+
+	go r.HandleRequest(context.Background(), RpcRequestPayload{ID: "1", Method: "lento"})
+
+	<-started
+
+	r.HandleCancel(context.Background(), RpcCancelPayload{ID: "1"})
+
+	response := decode[RpcResponsePayload](t, next(), RpcResponseEvent)
+
+	if response.Error == nil || response.Error.Code != RpcErrorCanceled {
+		t.Fatalf("esperava canceled, obteve %+v", response)
+	}
+}
+
+func TestRpcCall(t *testing.T) {
+	r, next := testRpc(t, CapabilityRpc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+
+	type result struct {
+		sum int
+		err error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		sum, err := Call[somaRequest, int](ctx, r, "soma", somaRequest{A: 2, B: 3})
+		done <- result{sum, err}
+	}()
+
+	request := decode[RpcRequestPayload](t, next(), RpcRequestEvent)
+
+	if request.Method != "soma" || request.Deadline != deadline.UnixMilli() {
+		t.Fatalf("requisição inesperada: %+v", request)
+	}
+
+	r.HandleResponse(context.Background(), RpcResponsePayload{ID: request.ID, Result: json.RawMessage("5")})
+
+	got := <-done
+
+	if got.err != nil || got.sum != 5 {
+		t.Fatalf("resultado inesperado: %+v", got)
+	}
+
+	go func() {
+		_, err := Call[struct{}, struct{}](ctx, r, "inexistente", struct{}{})
+		done <- result{err: err}
+	}()
+
+	request = decode[RpcRequestPayload](t, next(), RpcRequestEvent)
+
+	r.HandleResponse(context.Background(), RpcResponsePayload{
+		ID:    request.ID,
+		Error: &RpcError{Code: RpcErrorMethodNotFound, Message: "método não encontrado"},
+	})
+
+	got = <-done
+
+	var rpcErr *RpcError
+
+	if !errors.As(got.err, &rpcErr) || rpcErr.Code != RpcErrorMethodNotFound {
+		t.Fatalf("esperava method_not_found, obteve %v", got.err)
+	}
+}
+
+func TestRpcCallCancel(t *testing.T) {
+	r, next := testRpc(t, CapabilityRpc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := Call[struct{}, struct{}](ctx, r, "lento", struct{}{})
+		done <- err
+	}()
+
+	request := decode[RpcRequestPayload](t, next(), RpcRequestEvent)
+
+	if request.Deadline == 0 {
+		t.Fatal("chamada sem deadline deveria usar o tempo limite padrão")
+	}
+
+	cancel()
+
+	canceled := decode[RpcCancelPayload](t, next(), RpcCancelEvent)
+
+	if canceled.ID != request.ID {
+		t.Fatalf("cancelamento de outra chamada: %s", canceled.ID)
+	}
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("esperava context.Canceled, obteve %v", err)
+	}
+}
+
+func TestRpcCallCapability(t *testing.T) {
+	r, _ := testRpc(t)
+
+	_, err := Call[struct{}, struct{}](context.Background(), r, "soma", struct{}{})
+
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("esperava ErrCapabilityUnsupported, obteve %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+const (
+	// Inscrições, heartbeats, rpc e confirmações
+	PriorityControl Priority = iota
+	PriorityNormal
+	// Saída do pty, blocos de arquivos e dados de túneis
+	PriorityBulk
+)
+
+const (
+	// Capacidade da fila de cada prioridade
+	sendQueueSize = 256
+	// Tempo limite para a escrita de uma mensagem no socket
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	ErrQueueFull    = errors.New("fila de envio cheia")
+	ErrNotConnected = errors.New("não conectado ao servidor")
+)
+
+var eventPriorities = map[string]Priority{
+	PtyOutputEvent:       PriorityBulk,
+	ExecOutputEvent:      PriorityBulk,
+	FileChunkEvent:       PriorityBulk,
+	TunnelDataEvent:      PriorityBulk,
+	AgenteInventoryEvent: PriorityBulk,
+	AgenteMetricsEvent:   PriorityBulk,
+	TunnelAckEvent:       PriorityControl,
+	FileAckEvent:         PriorityControl,
+	RpcRequestEvent:      PriorityControl,
+	RpcResponseEvent:     PriorityControl,
+	RpcCancelEvent:       PriorityControl,
+	TunnelCloseEvent:     PriorityControl,
+	TunnelOpenedEvent:    PriorityControl,
+}
+
+func EventPriority(event string) Priority {
+	if priority, ok := eventPriorities[event]; ok {
+		return priority
+	}
+
+	return PriorityNormal
+}
+
+// outgoing é uma mensagem de saída. Se written não for nil, recebe o
+// resultado da escrita no socket.
+type outgoing struct {
+	msg     []byte
+	written chan error
+}
+
+// sendQueue guarda as mensagens de saída, separadas por prioridade, até que
+// a goroutine de escrita as envie.
+type sendQueue struct {
+	lanes   [3]chan outgoing
+	mu      sync.Mutex
+	pending int
+}
+
+func newSendQueue() *sendQueue {
+	q := &sendQueue{}
+
+	for i := range q.lanes {
+		q.lanes[i] = make(chan outgoing, sendQueueSize)
+	}
+
+	return q
+}
+
+func (q *sendQueue) add(delta int) {
+	q.mu.Lock()
+	q.pending += delta
+	q.mu.Unlock()
+}
+
+// push enfileira sem bloquear, retornando ErrQueueFull se não houver espaço.
+func (q *sendQueue) push(priority Priority, msg []byte) error {
+	q.add(1)
+
+	select {
+	case q.lanes[priority] <- outgoing{msg: msg}:
+		return nil
+	default:
+		q.add(-1)
+		return ErrQueueFull
+	}
+}
+
+// pushWait enfileira aguardando espaço até o contexto ou a conexão acabarem.
+func (q *sendQueue) pushWait(ctx context.Context, closed <-chan struct{}, priority Priority, msg []byte) error {
+	return q.pushOutgoing(ctx, closed, priority, outgoing{msg: msg})
+}
+
+// pushTracked enfileira como pushWait e retorna o canal que recebe o
+// resultado da escrita desta mensagem no socket.
+func (q *sendQueue) pushTracked(ctx context.Context, closed <-chan struct{}, priority Priority, msg []byte) (<-chan error, error) {
+	written := make(chan error, 1)
+
+	return written, q.pushOutgoing(ctx, closed, priority, outgoing{msg: msg, written: written})
+}
+
+func (q *sendQueue) pushOutgoing(ctx context.Context, closed <-chan struct{}, priority Priority, out outgoing) error {
+	q.add(1)
+
+	select {
+	case q.lanes[priority] <- out:
+		return nil
+	case <-ctx.Done():
+		q.add(-1)
+		return ctx.Err()
+	case <-closed:
+		q.add(-1)
+		return ErrNotConnected
+	}
+}
+
+// next retorna a próxima mensagem respeitando a prioridade.
+func (q *sendQueue) next(closed <-chan struct{}) (outgoing, bool) {
+	for _, lane := range q.lanes {
+		select {
+		case out := <-lane:
+			return out, true
+		default:
+		}
+	}
+
+	select {
+	case out := <-q.lanes[PriorityControl]:
+		return out, true
+	case out := <-q.lanes[PriorityNormal]:
+		return out, true
+	case out := <-q.lanes[PriorityBulk]:
+		return out, true
+	case <-closed:
+		return outgoing{}, false
+	}
+}
+
+// clear descarta as mensagens que sobraram de uma conexão anterior,
+// avisando quem aguarda a escrita delas.
+func (q *sendQueue) clear() {
+	for _, lane := range q.lanes {
+		for {
+			select {
+			case out := <-lane:
+				q.add(-1)
+
+				if out.written != nil {
+					out.written <- ErrNotConnected
+				}
+
+				continue
+			default:
+			}
+
+			break
+		}
+	}
+}
+
+func (q *sendQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.pending
+}
+
+// flush aguarda até que todas as mensagens enfileiradas tenham sido escritas.
+func (q *sendQueue) flush(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for q.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// Tipos de serviço que podem ser monitorados
+const (
+	ServicoProcesso = "process"
+	ServicoSystemd  = "systemd"
+	ServicoWindows  = "windows"
+)
+
+// ServiceRef identifica um processo, unidade do systemd ou serviço do
+// Windows, tanto na configuração quanto no manifest da implantação.
+type ServiceRef struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+const (
+	ServicoAtivo        = "ativo"
+	ServicoParado       = "parado"
+	ServicoFalha        = "falha"
+	ServicoTransicao    = "em_transicao"
+	ServicoDesconhecido = "desconhecido"
+)
+
+type ServicoStatusPayload struct {
+	Tipo   string `json:"tipo"`
+	Nome   string `json:"nome"`
+	Status string `json:"status"`
+	// Status anterior. Vazio no primeiro envio após a conexão
+	Anterior string    `json:"anterior,omitempty"`
+	Pids     []int32   `json:"pids,omitempty"`
+	Erro     string    `json:"erro,omitempty"`
+	Em       time.Time `json:"em"`
+}
+
+const (
+	ServicoStart   = "start"
+	ServicoStop    = "stop"
+	ServicoRestart = "restart"
+)
+
+type ServicoActionPayload struct {
+	ID   string `json:"id"`
+	Tipo string `json:"tipo"`
+	Nome string `json:"nome"`
+	// start, stop ou restart
+	Acao string `json:"acao"`
+}
+
+type ServicoActionResultPayload struct {
+	ID string `json:"id"`
+	// Status do serviço após a ação
+	Status string `json:"status,omitempty"`
+	Erro   string `json:"erro,omitempty"`
+}
+
+func (p ServicoActionPayload) Validate() error {
+	if p.ID == "" || p.Tipo == "" || p.Nome == "" {
+		return errors.New("id, tipo e nome são obrigatórios")
+	}
+
+	switch p.Acao {
+	case ServicoStart, ServicoStop, ServicoRestart:
+		return nil
+	}
+
+	return errors.New("ação inválida: " + p.Acao)
+}
@@ -0,0 +1,41 @@
+package pubsub
+
+import "errors"
+
+type TunnelOpenPayload struct {
+	StreamID string `json:"streamId"`
+	Target   string `json:"target"`
+	// Janela de recebimento do servidor em bytes
+	Window int64 `json:"window"`
+}
+
+type TunnelOpenedPayload struct {
+	StreamID string `json:"streamId"`
+	// Janela de recebimento do agente em bytes
+	Window int64  `json:"window"`
+	Error  string `json:"error,omitempty"`
+}
+
+type TunnelDataPayload struct {
+	StreamID string `json:"streamId"`
+	Seq      int64  `json:"seq"`
+	Data     []byte `json:"data"`
+}
+
+type TunnelAckPayload struct {
+	StreamID string `json:"streamId"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type TunnelClosePayload struct {
+	StreamID string `json:"streamId"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (p TunnelOpenPayload) Validate() error {
+	if p.StreamID == "" || p.Target == "" {
+		return errors.New("streamId e target são obrigatórios")
+	}
+
+	return nil
+}
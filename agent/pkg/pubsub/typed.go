@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EventMetadata struct {
+	MessageID  string
+	Event      string
+	ReceivedAt time.Time
+}
+
+type metadataKey struct{}
+
+func withMetadata(ctx context.Context, meta EventMetadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, meta)
+}
+
+// MetadataFrom retorna os metadados do evento que originou o contexto.
+func MetadataFrom(ctx context.Context) (EventMetadata, bool) {
+	meta, ok := ctx.Value(metadataKey{}).(EventMetadata)
+
+	return meta, ok
+}
+
+// Validator é implementado pelos payloads que precisam de validação além da
+// decodificação do JSON.
+type Validator interface {
+	Validate() error
+}
+
+type TypedHandler[T any] func(ctx context.Context, payload T)
+
+type ErrorHandler func(meta EventMetadata, err error)
+
+type DecodeError struct {
+	Event string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("payload inválido para o evento %s: %v", e.Event, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DroppedTyped adapta handler para DispatchPolicy.OnFull, decodificando o
+// payload da mensagem descartada. Payloads inválidos são ignorados.
+func DroppedTyped[T any](handler TypedHandler[T]) func(ctx context.Context, data string) {
+	return func(ctx context.Context, data string) {
+		var payload T
+
+		if json.Unmarshal([]byte(data), &payload) != nil {
+			return
+		}
+
+		handler(ctx, payload)
+	}
+}
+
+// SubscribeTyped registra um handler que recebe o payload já decodificado e
+// validado. Falhas são enviadas ao ErrorHandler do PubSub.
+func SubscribeTyped[T any](p *PubSub, event string, handler TypedHandler[T]) {
+	p.subscribe(event, func(ctx context.Context, data string) {
+		var payload T
+
+		err := json.Unmarshal([]byte(data), &payload)
+
+		if err == nil {
+			if v, ok := any(&payload).(Validator); ok {
+				err = v.Validate()
+			}
+		}
+
+		if err != nil {
+			p.reportError(ctx, &DecodeError{Event: event, Err: err})
+			return
+		}
+
+		handler(ctx, payload)
+	})
+}
@@ -0,0 +1,234 @@
+package secret
+
+import (
+	"agent/pkg/system"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	saltSize = 16
+	keyInfo  = "vrdeploy secret store"
+)
+
+// FileStore guarda os segredos em um arquivo JSON com permissão 0600. No
+// modo cifrado o conteúdo é protegido com AES-256-GCM usando uma chave
+// derivada do identificador da máquina, de forma que o arquivo não pode ser
+// lido se copiado para outro equipamento.
+type FileStore struct {
+	path      string
+	machineID string
+	mu        sync.Mutex
+}
+
+func NewPlainFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func NewEncryptedFileStore(path string) (*FileStore, error) {
+	id, err := system.MachineID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{path: path, machineID: id}, nil
+}
+
+func (s *FileStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[key]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (s *FileStore) Set(key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+
+	if err != nil {
+		return err
+	}
+
+	secrets[key] = value
+
+	return s.save(secrets)
+}
+
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[key]; !ok {
+		return nil
+	}
+
+	delete(secrets, key)
+
+	if len(secrets) == 0 {
+		return os.Remove(s.path)
+	}
+
+	return s.save(secrets)
+}
+
+func (s *FileStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+
+	data, err := os.ReadFile(s.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.machineID != "" {
+		data, err = s.decrypt(data)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(data, &secrets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+func (s *FileStore) save(secrets map[string]string) error {
+	data, err := json.Marshal(secrets)
+
+	if err != nil {
+		return err
+	}
+
+	if s.machineID != "" {
+		data, err = s.encrypt(data)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// O arquivo cifrado é composto por salt, nonce e o conteúdo cifrado.
+func (s *FileStore) encrypt(plain []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+
+	_, err := rand.Read(salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := s.cipher(salt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(salt, nonce...)
+
+	return gcm.Seal(out, nonce, plain, nil), nil
+}
+
+func (s *FileStore) decrypt(data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, errors.New("arquivo de segredos inválido")
+	}
+
+	gcm, err := s.cipher(data[:saltSize])
+
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[saltSize:]
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("arquivo de segredos inválido")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+
+	if err != nil {
+		return nil, errors.New("não foi possível decifrar o arquivo de segredos")
+	}
+
+	return plain, nil
+}
+
+func (s *FileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, []byte(s.machineID), salt, keyInfo, 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
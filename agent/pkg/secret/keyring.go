@@ -0,0 +1,49 @@
+package secret
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringStore usa o cofre do sistema operacional (Secret Service no Linux,
+// Credential Manager no Windows).
+type KeyringStore struct {
+	service string
+}
+
+func NewKeyringStore() *KeyringStore {
+	return &KeyringStore{service: service}
+}
+
+func (s *KeyringStore) Get(key string) (string, error) {
+	value, err := keyring.Get(s.service, key)
+
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+
+	return value, err
+}
+
+func (s *KeyringStore) Set(key string, value string) error {
+	return keyring.Set(s.service, key, value)
+}
+
+func (s *KeyringStore) Delete(key string) error {
+	err := keyring.Delete(s.service, key)
+
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// available informa se o cofre pode ser usado. Em máquinas sem D-Bus, por
+// exemplo, qualquer acesso falha.
+func (s *KeyringStore) available() bool {
+	_, err := s.Get(TokenKey)
+
+	return err == nil || errors.Is(err, ErrNotFound)
+}
@@ -0,0 +1,44 @@
+package secret
+
+import "sync"
+
+// MemoryStore mantém os segredos apenas em memória. Usado em testes.
+type MemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{secrets: make(map[string]string)}
+}
+
+func (s *MemoryStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.secrets[key]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (s *MemoryStore) Set(key string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secrets[key] = value
+
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.secrets, key)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package secret
+
+import (
+	"agent/pkg/config"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+const (
+	BackendAuto          = "auto"
+	BackendKeyring       = "keyring"
+	BackendEncryptedFile = "encrypted-file"
+	BackendFile          = "file"
+)
+
+// Ordem de preferência no modo automático
+var backendOrder = []string{BackendKeyring, BackendEncryptedFile, BackendFile}
+
+// Open cria o backend configurado. Segredos encontrados nos demais backends
+// são migrados para ele, permitindo trocar de backend sem recadastrar o
+// agente.
+func Open(cfg config.SecretConfig, dataDir string) (SecretStore, error) {
+	backends := map[string]SecretStore{}
+
+	keyringStore := NewKeyringStore()
+
+	if keyringStore.available() {
+		backends[BackendKeyring] = keyringStore
+	}
+
+	encryptedPath := filepath.Join(dataDir, "secrets.enc")
+	plainPath := filepath.Join(dataDir, "secrets.json")
+
+	if cfg.Path != "" {
+		switch cfg.Backend {
+		case BackendEncryptedFile:
+			encryptedPath = cfg.Path
+		case BackendFile:
+			plainPath = cfg.Path
+		}
+	}
+
+	encryptedStore, err := NewEncryptedFileStore(encryptedPath)
+
+	if err == nil {
+		backends[BackendEncryptedFile] = encryptedStore
+	} else {
+		log.Println("Arquivo de segredos cifrado indisponível:", err)
+	}
+
+	backends[BackendFile] = NewPlainFileStore(plainPath)
+
+	name := cfg.Backend
+
+	if name == "" || name == BackendAuto {
+		for _, candidate := range backendOrder {
+			if _, ok := backends[candidate]; ok {
+				name = candidate
+				break
+			}
+		}
+	}
+
+	store, ok := backends[name]
+
+	if !ok {
+		return nil, fmt.Errorf("backend de segredos indisponível: %s", name)
+	}
+
+	for _, key := range keys {
+		for _, source := range backendOrder {
+			if other, ok := backends[source]; ok && source != name {
+				migrate(key, store, source, other)
+			}
+		}
+	}
+
+	return store, nil
+}
+
+// migrate move a chave da origem para o destino caso ela ainda não exista
+// no destino.
+func migrate(key string, target SecretStore, name string, source SecretStore) {
+	if _, err := target.Get(key); !errors.Is(err, ErrNotFound) {
+		return
+	}
+
+	value, err := source.Get(key)
+
+	if err != nil {
+		return
+	}
+
+	err = target.Set(key, value)
+
+	if err != nil {
+		log.Printf("Erro ao migrar segredo %s de %s: %v", key, name, err)
+		return
+	}
+
+	err = source.Delete(key)
+
+	if err != nil {
+		log.Printf("Erro ao remover segredo %s de %s: %v", key, name, err)
+	}
+
+	log.Printf("Segredo %s migrado de %s", key, name)
+}
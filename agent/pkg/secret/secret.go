@@ -1,20 +1,74 @@
 package secret
 
-import "github.com/zalando/go-keyring"
+import (
+	"errors"
+	"sync"
+)
+
+const service = "br.com.vrsoft.vrdeploy"
 
 const (
-	service = "br.com.vrsoft.vrdeploy"
-	user    = "agent"
+	// Token de acesso emitido pelo servidor (agentes cadastrados antes da
+	// identidade por chave)
+	TokenKey = "agent"
+	// Seed da chave privada Ed25519 do agente
+	PrivateKeyKey = "chave-privada"
+	// Data de criação da chave privada (RFC 3339)
+	PrivateKeyCreatedAtKey = "chave-privada-criada-em"
+	// Chave anterior mantida durante uma rotação até a nova ser aceita
+	PreviousPrivateKeyKey = "chave-privada-anterior"
+	// Token anterior mantido durante uma rotação até a nova chave ser aceita
+	PreviousTokenKey = "agent-anterior"
 )
 
+var ErrNotFound = errors.New("segredo não encontrado")
+
+// Chaves guardadas pelo agente, migradas ao trocar de backend
+var keys = []string{
+	TokenKey,
+	PrivateKeyKey,
+	PrivateKeyCreatedAtKey,
+	PreviousPrivateKeyKey,
+	PreviousTokenKey,
+}
+
+// SecretStore guarda os segredos do agente. Delete não retorna erro quando
+// a chave não existe.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+var (
+	mu      sync.RWMutex
+	current SecretStore = NewKeyringStore()
+)
+
+// Use define o backend usado pelas funções do pacote.
+func Use(store SecretStore) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = store
+}
+
+// Current retorna o backend em uso.
+func Current() SecretStore {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
 func Get() (string, error) {
-	return keyring.Get(service, user)
+	return Current().Get(TokenKey)
 }
 
 func Set(secret string) error {
-	return keyring.Set(service, user, secret)
+	return Current().Set(TokenKey, secret)
 }
 
 func Delete() error {
-	return keyring.Delete(service, user)
+	return Current().Delete(TokenKey)
 }
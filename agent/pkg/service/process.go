@@ -0,0 +1,127 @@
+package service
+
+import (
+	"agent/pkg/pubsub"
+	"context"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Tempo aguardado após o sinal de término antes de finalizar à força
+const stopGrace = 10 * time.Second
+
+// processController monitora processos pelo nome do executável. Processos
+// não têm como ser iniciados sem um gerenciador, então apenas o stop é
+// suportado.
+type processController struct{}
+
+func (processController) Status(ctx context.Context, name string) (Status, error) {
+	procs, err := findProcesses(ctx, name)
+
+	if err != nil {
+		return Status{State: pubsub.ServicoDesconhecido}, err
+	}
+
+	if len(procs) == 0 {
+		return Status{State: pubsub.ServicoParado}, nil
+	}
+
+	status := Status{State: pubsub.ServicoAtivo}
+
+	for _, proc := range procs {
+		status.Pids = append(status.Pids, proc.Pid)
+	}
+
+	return status, nil
+}
+
+func (processController) Start(ctx context.Context, name string) error {
+	return ErrUnsupported
+}
+
+func (processController) Stop(ctx context.Context, name string) error {
+	procs, err := findProcesses(ctx, name)
+
+	if err != nil {
+		return err
+	}
+
+	for _, proc := range procs {
+		// No Windows não há SIGTERM e o processo é finalizado diretamente
+		if err := proc.TerminateWithContext(ctx); err != nil {
+			proc.KillWithContext(ctx)
+		}
+	}
+
+	deadline := time.Now().Add(stopGrace)
+
+	for _, proc := range procs {
+		for time.Now().Before(deadline) {
+			running, err := proc.IsRunningWithContext(ctx)
+
+			if err != nil || !running {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+
+		if running, err := proc.IsRunningWithContext(ctx); err == nil && running {
+			err = proc.KillWithContext(ctx)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (processController) Restart(ctx context.Context, name string) error {
+	return ErrUnsupported
+}
+
+func findProcesses(ctx context.Context, name string) ([]*process.Process, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*process.Process
+
+	for _, proc := range procs {
+		procName, err := proc.NameWithContext(ctx)
+
+		if err != nil {
+			continue
+		}
+
+		if sameProcessName(procName, name) {
+			found = append(found, proc)
+		}
+	}
+
+	return found, nil
+}
+
+// sameProcessName compara os nomes. No Windows ignora a extensão .exe e a
+// diferença entre maiúsculas e minúsculas.
+func sameProcessName(a string, b string) bool {
+	if runtime.GOOS != "windows" {
+		return a == b
+	}
+
+	a = strings.TrimSuffix(strings.ToLower(a), ".exe")
+	b = strings.TrimSuffix(strings.ToLower(b), ".exe")
+
+	return a == b
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"agent/pkg/pubsub"
+	"context"
+	"errors"
+)
+
+var ErrUnsupported = errors.New("operação não suportada para este tipo de serviço")
+
+type Status struct {
+	// Um dos valores pubsub.Servico* (ativo, parado, falha...)
+	State string
+	Pids  []int32
+}
+
+// Controller consulta e controla um tipo de serviço. As ações remotas e o
+// monitoramento usam a mesma implementação.
+type Controller interface {
+	Status(ctx context.Context, name string) (Status, error)
+	Start(ctx context.Context, name string) error
+	Stop(ctx context.Context, name string) error
+	Restart(ctx context.Context, name string) error
+}
+
+// controllerFor retorna o controller do tipo informado. Tipos de outra
+// plataforma (ex: systemd no Windows) não são suportados.
+func controllerFor(kind string) (Controller, error) {
+	if kind == pubsub.ServicoProcesso {
+		return processController{}, nil
+	}
+
+	controller, ok := platformControllers[kind]
+
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	return controller, nil
+}
+
+// Apply executa a ação informada no serviço.
+func Apply(ctx context.Context, ref pubsub.ServiceRef, action string) error {
+	controller, err := controllerFor(ref.Type)
+
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case pubsub.ServicoStart:
+		return controller.Start(ctx, ref.Name)
+	case pubsub.ServicoStop:
+		return controller.Stop(ctx, ref.Name)
+	case pubsub.ServicoRestart:
+		return controller.Restart(ctx, ref.Name)
+	}
+
+	return errors.New("ação inválida: " + action)
+}
+
+// Query retorna o status atual do serviço.
+func Query(ctx context.Context, ref pubsub.ServiceRef) (Status, error) {
+	controller, err := controllerFor(ref.Type)
+
+	if err != nil {
+		return Status{State: pubsub.ServicoDesconhecido}, err
+	}
+
+	return controller.Status(ctx, ref.Name)
+}
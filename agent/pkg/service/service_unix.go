@@ -0,0 +1,103 @@
+//go:build !windows
+
+package service
+
+import (
+	"agent/pkg/pubsub"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var platformControllers = map[string]Controller{
+	pubsub.ServicoSystemd: systemdController{},
+}
+
+type systemdController struct{}
+
+func (systemdController) Status(ctx context.Context, name string) (Status, error) {
+	output, err := systemctl(ctx, "show", "--property=LoadState,ActiveState,MainPID", "--", name)
+
+	if err != nil {
+		return Status{State: pubsub.ServicoDesconhecido}, err
+	}
+
+	props := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+
+		if ok {
+			props[key] = value
+		}
+	}
+
+	if props["LoadState"] == "not-found" {
+		return Status{State: pubsub.ServicoDesconhecido}, fmt.Errorf("unidade não encontrada: %s", name)
+	}
+
+	status := Status{}
+
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		status.State = pubsub.ServicoAtivo
+	case "inactive":
+		status.State = pubsub.ServicoParado
+	case "failed":
+		status.State = pubsub.ServicoFalha
+	case "activating", "deactivating":
+		status.State = pubsub.ServicoTransicao
+	default:
+		status.State = pubsub.ServicoDesconhecido
+	}
+
+	if pid, err := strconv.ParseInt(props["MainPID"], 10, 32); err == nil && pid > 0 {
+		status.Pids = []int32{int32(pid)}
+	}
+
+	return status, nil
+}
+
+func (systemdController) Start(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "start", "--", name)
+
+	return err
+}
+
+func (systemdController) Stop(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "stop", "--", name)
+
+	return err
+}
+
+func (systemdController) Restart(ctx context.Context, name string) error {
+	_, err := systemctl(ctx, "restart", "--", name)
+
+	return err
+}
+
+// systemctl executa o comando e inclui a mensagem de erro na falha.
+func systemctl(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "systemctl", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return nil, err
+	}
+
+	return output, nil
+}
@@ -0,0 +1,154 @@
+//go:build windows
+
+package service
+
+import (
+	"agent/pkg/pubsub"
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var platformControllers = map[string]Controller{
+	pubsub.ServicoWindows: windowsController{},
+}
+
+type windowsController struct{}
+
+func (windowsController) Status(ctx context.Context, name string) (Status, error) {
+	status := Status{State: pubsub.ServicoDesconhecido}
+
+	err := withService(name, func(s *mgr.Service) error {
+		query, err := s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		switch query.State {
+		case svc.Running:
+			status.State = pubsub.ServicoAtivo
+		case svc.Stopped:
+			status.State = pubsub.ServicoParado
+
+			// Serviço parado com código de saída indica falha
+			if query.Win32ExitCode != 0 || query.ServiceSpecificExitCode != 0 {
+				status.State = pubsub.ServicoFalha
+			}
+		case svc.Paused:
+			status.State = pubsub.ServicoParado
+		default:
+			status.State = pubsub.ServicoTransicao
+		}
+
+		if query.ProcessId != 0 {
+			status.Pids = []int32{int32(query.ProcessId)}
+		}
+
+		return nil
+	})
+
+	return status, err
+}
+
+func (windowsController) Start(ctx context.Context, name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		query, err := s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		if query.State == svc.Running {
+			return nil
+		}
+
+		err = s.Start()
+
+		if err != nil {
+			return err
+		}
+
+		return waitState(ctx, s, svc.Running)
+	})
+}
+
+func (windowsController) Stop(ctx context.Context, name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		query, err := s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		if query.State == svc.Stopped {
+			return nil
+		}
+
+		_, err = s.Control(svc.Stop)
+
+		if err != nil {
+			return err
+		}
+
+		return waitState(ctx, s, svc.Stopped)
+	})
+}
+
+func (c windowsController) Restart(ctx context.Context, name string) error {
+	err := c.Stop(ctx, name)
+
+	if err != nil {
+		return err
+	}
+
+	return c.Start(ctx, name)
+}
+
+func withService(name string, fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+
+	if err != nil {
+		return err
+	}
+
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	return fn(s)
+}
+
+// waitState aguarda o serviço atingir o estado informado.
+func waitState(ctx context.Context, s *mgr.Service, state svc.State) error {
+	for {
+		query, err := s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		if query.State == state {
+			return nil
+		}
+
+		if state == svc.Running && query.State == svc.Stopped {
+			return errors.New("o serviço parou durante a inicialização")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrNotWatched = errors.New("serviço não está na lista monitorada")
+
+const (
+	// Tempo limite de cada consulta de status
+	queryTimeout = 10 * time.Second
+	// Tempo limite das ações de start, stop e restart
+	actionTimeout = 2 * time.Minute
+)
+
+// Watcher verifica periodicamente os serviços da configuração e do manifest
+// da última implantação, publicando servico:status nas transições.
+type Watcher struct {
+	ps      *pubsub.PubSub
+	journal *pubsub.ImplantacaoJournal
+	cfg     config.ServicesConfig
+	mu      sync.Mutex
+	last    map[pubsub.ServiceRef]string
+}
+
+func NewWatcher(ps *pubsub.PubSub, journal *pubsub.ImplantacaoJournal, cfg config.ServicesConfig) *Watcher {
+	return &Watcher{
+		ps:      ps,
+		journal: journal,
+		cfg:     cfg,
+		last:    make(map[pubsub.ServiceRef]string),
+	}
+}
+
+// OnConnect envia o status de todos os serviços, já que as transições
+// ocorridas durante a desconexão não foram entregues.
+func (w *Watcher) OnConnect(ctx context.Context) {
+	w.poll(ctx, true)
+}
+
+// Run verifica os serviços periodicamente até o contexto ser cancelado.
+func (w *Watcher) Run(ctx context.Context) {
+	if w.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(w.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll(ctx, false)
+		}
+	}
+}
+
+func (w *Watcher) HandleAction(ctx context.Context, payload pubsub.ServicoActionPayload) {
+	result := pubsub.ServicoActionResultPayload{ID: payload.ID}
+
+	ref := pubsub.ServiceRef{Type: payload.Tipo, Name: payload.Nome}
+
+	err := w.apply(ctx, ref, payload.Acao)
+
+	if err != nil {
+		result.Erro = err.Error()
+	}
+
+	// Publica a transição causada pela ação sem esperar a próxima verificação
+	if slices.Contains(w.services(), ref) {
+		result.Status = w.check(ctx, ref, false)
+	}
+
+	data, err := json.Marshal(result)
+
+	if err != nil {
+		fmt.Println("Erro ao serializar resultado da ação:", err)
+		return
+	}
+
+	err = w.ps.Publish(pubsub.ServicoActionResultEvent, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar resultado da ação:", err)
+	}
+}
+
+func (w *Watcher) apply(ctx context.Context, ref pubsub.ServiceRef, action string) error {
+	// Apenas serviços monitorados podem ser controlados remotamente
+	if !slices.Contains(w.services(), ref) {
+		return ErrNotWatched
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, actionTimeout)
+	defer cancel()
+
+	fmt.Printf("Executando %s no serviço %s (%s)\n", action, ref.Name, ref.Type)
+
+	return Apply(ctx, ref, action)
+}
+
+// services retorna a lista monitorada, sem repetições.
+func (w *Watcher) services() []pubsub.ServiceRef {
+	var refs []pubsub.ServiceRef
+
+	for _, watch := range w.cfg.Watch {
+		refs = append(refs, pubsub.ServiceRef{Type: watch.Type, Name: watch.Name})
+	}
+
+	if entry, ok := w.journal.LastConcluded(); ok {
+		refs = append(refs, entry.Servicos...)
+	}
+
+	var unique []pubsub.ServiceRef
+
+	for _, ref := range refs {
+		if ref.Name != "" && !slices.Contains(unique, ref) {
+			unique = append(unique, ref)
+		}
+	}
+
+	return unique
+}
+
+func (w *Watcher) poll(ctx context.Context, all bool) {
+	services := w.services()
+
+	for _, ref := range services {
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.check(ctx, ref, all)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Serviços que deixaram de ser monitorados
+	for ref := range w.last {
+		if !slices.Contains(services, ref) {
+			delete(w.last, ref)
+		}
+	}
+}
+
+// check consulta o status do serviço e o publica se ele mudou ou se force
+// for verdadeiro. Retorna o status atual.
+func (w *Watcher) check(ctx context.Context, ref pubsub.ServiceRef, force bool) string {
+	queryCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	status, err := Query(queryCtx, ref)
+
+	w.mu.Lock()
+	previous, known := w.last[ref]
+	w.last[ref] = status.State
+	w.mu.Unlock()
+
+	if known && previous == status.State && !force {
+		return status.State
+	}
+
+	payload := pubsub.ServicoStatusPayload{
+		Tipo:   ref.Type,
+		Nome:   ref.Name,
+		Status: status.State,
+		Pids:   status.Pids,
+		Em:     time.Now(),
+	}
+
+	if known && previous != status.State {
+		payload.Anterior = previous
+	}
+
+	if err != nil {
+		payload.Erro = err.Error()
+	}
+
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		fmt.Println("Erro ao serializar status do serviço:", err)
+		return status.State
+	}
+
+	err = w.ps.Publish(pubsub.ServicoStatusEvent, string(data))
+
+	// Desconectado: o status completo é enviado na próxima conexão
+	if err != nil && !errors.Is(err, pubsub.ErrNotConnected) {
+		fmt.Println("Erro ao publicar status do serviço:", err)
+	}
+
+	return status.State
+}
@@ -0,0 +1,105 @@
+package system
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// UUIDs genéricos gravados por fabricantes que não personalizam o SMBIOS
+var invalidProductUUIDs = []string{
+	"00000000-0000-0000-0000-000000000000",
+	"ffffffff-ffff-ffff-ffff-ffffffffffff",
+	"03000200-0400-0500-0006-000700080009",
+}
+
+// Fingerprint identifica a máquina de forma estável. Os componentes são
+// enviados apenas como hash e permitem ao servidor reconhecer a máquina
+// mesmo que um deles mude (ex: troca de placa de rede).
+type Fingerprint struct {
+	// Derivado apenas do machine-id (MachineGuid no Windows), legível por
+	// qualquer usuário; na falta dele, dos MACs físicos. O UUID da
+	// placa-mãe só pode ser lido pelo root no Linux e por isso não entra
+	// no ID, para que o setup e o serviço calculem o mesmo valor
+	ID          string   `json:"id"`
+	MachineID   string   `json:"machineId,omitempty"`
+	ProductUUID string   `json:"productUuid,omitempty"`
+	Macs        []string `json:"macs,omitempty"`
+}
+
+var machineFingerprint = sync.OnceValues(computeFingerprint)
+
+// MachineFingerprint retorna o fingerprint da máquina, calculado uma única
+// vez por execução.
+func MachineFingerprint() (Fingerprint, error) {
+	return machineFingerprint()
+}
+
+func computeFingerprint() (Fingerprint, error) {
+	var fingerprint Fingerprint
+
+	machineID, err := MachineID()
+
+	if err != nil {
+		machineID = ""
+	}
+
+	uuid, err := productUUID()
+
+	if err != nil || !validProductUUID(uuid) {
+		uuid = ""
+	}
+
+	macs, err := PhysicalMacs()
+
+	if err != nil {
+		macs = nil
+	}
+
+	if machineID != "" {
+		fingerprint.MachineID = hash(machineID)
+	}
+
+	if uuid != "" {
+		fingerprint.ProductUUID = hash(strings.ToLower(uuid))
+	}
+
+	for _, mac := range macs {
+		fingerprint.Macs = append(fingerprint.Macs, hash(mac))
+	}
+
+	switch {
+	case machineID != "":
+		fingerprint.ID = hash(machineID)
+	case len(macs) > 0:
+		fingerprint.ID = hash(strings.Join(macs, ","))
+	default:
+		return fingerprint, errors.New("nenhum identificador da máquina disponível")
+	}
+
+	return fingerprint, nil
+}
+
+func validProductUUID(uuid string) bool {
+	uuid = strings.ToLower(uuid)
+
+	if uuid == "" {
+		return false
+	}
+
+	for _, invalid := range invalidProductUUIDs {
+		if uuid == invalid {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hash(value string) string {
+	sum := sha256.Sum256([]byte("vrdeploy:" + value))
+
+	return hex.EncodeToString(sum[:])
+}
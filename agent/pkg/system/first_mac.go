@@ -5,7 +5,15 @@ import (
 	"net"
 )
 
+// MacAddress retorna o MAC da primeira placa de rede física. Se nenhuma for
+// encontrada, usa a primeira interface ativa que não seja loopback.
 func MacAddress() (string, error) {
+	macs, err := PhysicalMacs()
+
+	if err == nil && len(macs) > 0 {
+		return macs[0], nil
+	}
+
 	ifaces, err := net.Interfaces()
 
 	if err != nil {
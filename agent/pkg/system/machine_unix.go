@@ -0,0 +1,43 @@
+//go:build !windows
+
+package system
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+var machineIDPaths = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id",
+}
+
+// MachineID retorna o identificador da instalação do sistema operacional.
+func MachineID() (string, error) {
+	for _, path := range machineIDPaths {
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			continue
+		}
+
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", errors.New("identificador da máquina não encontrado")
+}
+
+// productUUID retorna o UUID da placa-mãe informado pelo DMI. No Linux o
+// arquivo só pode ser lido pelo root.
+func productUUID() (string, error) {
+	data, err := os.ReadFile("/sys/class/dmi/id/product_uuid")
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
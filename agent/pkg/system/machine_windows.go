@@ -0,0 +1,46 @@
+//go:build windows
+
+package system
+
+import (
+	"os/exec"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// MachineID retorna o identificador da instalação do sistema operacional.
+func MachineID() (string, error) {
+	key, err := registry.OpenKey(
+		registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Cryptography`,
+		registry.QUERY_VALUE|registry.WOW64_64KEY,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer key.Close()
+
+	id, _, err := key.GetStringValue("MachineGuid")
+
+	return id, err
+}
+
+// productUUID retorna o UUID da placa-mãe informado pelo SMBIOS.
+func productUUID() (string, error) {
+	output, err := exec.Command(
+		"powershell",
+		"-NoProfile",
+		"-NonInteractive",
+		"-Command",
+		"(Get-CimInstance -ClassName Win32_ComputerSystemProduct).UUID",
+	).Output()
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
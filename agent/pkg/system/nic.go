@@ -0,0 +1,81 @@
+package system
+
+import (
+	"net"
+	"slices"
+	"strings"
+)
+
+// Prefixos de fabricante (OUI) usados por hypervisors e containers
+var virtualOUIs = []string{
+	"00:05:69", "00:0c:29", "00:1c:14", "00:50:56", // VMware
+	"08:00:27", "0a:00:27", // VirtualBox
+	"00:15:5d", // Hyper-V
+	"00:1c:42", // Parallels
+	"00:16:3e", // Xen
+	"52:54:00", // QEMU/KVM
+	"02:42",    // Docker
+}
+
+// Trechos de nome de interfaces virtuais, VPNs e adaptadores removíveis
+var virtualNames = []string{
+	"docker", "veth", "br-", "virbr", "vmnet", "vboxnet", "tun", "tap",
+	"wg", "zt", "vethernet", "virtualbox", "vmware", "hyper-v", "loopback",
+	"bluetooth", "npcap", "teredo", "isatap",
+}
+
+// PhysicalMacs retorna, ordenados, os endereços MAC das placas de rede
+// físicas, incluindo as desligadas, para que o resultado não dependa do
+// estado das interfaces.
+func PhysicalMacs() ([]string, error) {
+	ifaces, err := net.Interfaces()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var macs []string
+
+	for _, ifi := range ifaces {
+		if isPhysical(ifi) {
+			macs = append(macs, ifi.HardwareAddr.String())
+		}
+	}
+
+	slices.Sort(macs)
+
+	return slices.Compact(macs), nil
+}
+
+func isPhysical(ifi net.Interface) bool {
+	if ifi.Flags&net.FlagLoopback != 0 || len(ifi.HardwareAddr) != 6 {
+		return false
+	}
+
+	// Endereços administrados localmente são gerados por software
+	if ifi.HardwareAddr[0]&0x02 != 0 {
+		return false
+	}
+
+	mac := ifi.HardwareAddr.String()
+
+	for _, prefix := range virtualOUIs {
+		if strings.HasPrefix(mac, prefix) {
+			return false
+		}
+	}
+
+	if physical, ok := nicPhysical(ifi.Name); ok {
+		return physical
+	}
+
+	name := strings.ToLower(ifi.Name)
+
+	for _, fragment := range virtualNames {
+		if strings.Contains(name, fragment) {
+			return false
+		}
+	}
+
+	return true
+}
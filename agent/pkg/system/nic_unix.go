@@ -0,0 +1,29 @@
+//go:build !windows
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nicPhysical consulta o sysfs: interfaces virtuais (bridges, veth, tun,
+// docker) não possuem o link "device", e as conectadas via USB são
+// removíveis e não servem para identificar a máquina. Retorna ok false
+// quando o sysfs não está disponível.
+func nicPhysical(name string) (physical bool, ok bool) {
+	base := filepath.Join("/sys/class/net", name)
+
+	if _, err := os.Stat(base); err != nil {
+		return false, false
+	}
+
+	device, err := filepath.EvalSymlinks(filepath.Join(base, "device"))
+
+	if err != nil {
+		return false, true
+	}
+
+	return !strings.Contains(device, "/usb"), true
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package system
+
+// nicPhysical não tem como consultar o barramento da interface sem WMI; a
+// classificação fica a cargo das heurísticas de nome e fabricante.
+func nicPhysical(name string) (physical bool, ok bool) {
+	return false, false
+}
@@ -0,0 +1,30 @@
+package system
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PathWithin indica se path é absoluto e está dentro de algum dos diretórios
+// informados.
+func PathWithin(roots []string, path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+
+	path = filepath.Clean(path)
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(filepath.Clean(root), path)
+
+		if err != nil {
+			continue
+		}
+
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,476 @@
+package transfer
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limite superior do bloco, independente da configuração
+const maxChunkSize = 1 << 20
+
+// Tempo em segundos aguardando um file:ack quando não configurado
+const defaultAckTimeout = 60
+
+// Sufixo do arquivo temporário usado durante o envio
+const partSuffix = ".part"
+
+var ErrDisabled = errors.New("transferência de arquivos desabilitada")
+
+var ErrAckTimeout = errors.New("tempo esgotado aguardando confirmação (file:ack) do servidor")
+
+type Manager struct {
+	ps  *pubsub.PubSub
+	cfg config.TransferConfig
+	// Serializa a escrita dos blocos recebidos
+	putMu sync.Mutex
+	// Downloads em andamento, indexados pelo id da transferência
+	gets   map[string]*download
+	getsMu sync.Mutex
+}
+
+// download controla quantos bytes de um file:get ainda aguardam
+// confirmação do servidor.
+type download struct {
+	mu     sync.Mutex
+	acked  int64
+	signal chan struct{}
+}
+
+func NewManager(ps *pubsub.PubSub, cfg config.TransferConfig) *Manager {
+	if cfg.ChunkSize <= 0 || cfg.ChunkSize > maxChunkSize {
+		cfg.ChunkSize = maxChunkSize
+	}
+
+	if cfg.Window < int64(cfg.ChunkSize) {
+		cfg.Window = int64(cfg.ChunkSize)
+	}
+
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = defaultAckTimeout
+	}
+
+	return &Manager{
+		ps:   ps,
+		cfg:  cfg,
+		gets: make(map[string]*download),
+	}
+}
+
+func (m *Manager) openRoot() (*os.Root, error) {
+	if !m.cfg.Enabled {
+		return nil, ErrDisabled
+	}
+
+	if !filepath.IsAbs(m.cfg.Root) {
+		return nil, fmt.Errorf("diretório raiz inválido: %q", m.cfg.Root)
+	}
+
+	return os.OpenRoot(m.cfg.Root)
+}
+
+// relPath converte o caminho recebido do servidor em um caminho relativo à
+// raiz configurada.
+func relPath(p string) string {
+	rel := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+
+	if rel == "" {
+		return "."
+	}
+
+	return filepath.FromSlash(rel)
+}
+
+func (m *Manager) HandleGet(ctx context.Context, payload pubsub.FileGetPayload) {
+	err := m.get(ctx, payload)
+
+	if err != nil {
+		m.publish(ctx, pubsub.FileChunkEvent, pubsub.FileChunkPayload{
+			ID:     payload.ID,
+			Path:   payload.Path,
+			Offset: payload.Offset,
+			EOF:    true,
+			Error:  err.Error(),
+		})
+	}
+}
+
+func (m *Manager) get(ctx context.Context, payload pubsub.FileGetPayload) error {
+	root, err := m.openRoot()
+
+	if err != nil {
+		return err
+	}
+
+	defer root.Close()
+
+	file, err := root.Open(relPath(payload.Path))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("caminho é um diretório: %s", payload.Path)
+	}
+
+	offset := payload.Offset
+
+	if offset < 0 || offset > info.Size() {
+		return fmt.Errorf("offset inválido: %d", offset)
+	}
+
+	window := payload.Window
+
+	if window <= 0 {
+		window = m.cfg.Window
+	}
+
+	window = max(window, int64(m.cfg.ChunkSize))
+
+	d, err := m.startDownload(payload.ID, offset)
+
+	if err != nil {
+		return err
+	}
+
+	defer m.endDownload(payload.ID)
+
+	buf := make([]byte, m.cfg.ChunkSize)
+
+	for {
+		err := d.wait(ctx, offset, window, time.Duration(m.cfg.AckTimeout)*time.Second)
+
+		if err != nil {
+			return err
+		}
+
+		n, err := file.ReadAt(buf, offset)
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		chunk := pubsub.FileChunkPayload{
+			ID:     payload.ID,
+			Path:   payload.Path,
+			Offset: offset,
+			Size:   info.Size(),
+			Data:   buf[:n],
+			EOF:    err == io.EOF || offset+int64(n) >= info.Size(),
+		}
+
+		if chunk.EOF {
+			chunk.Checksum, err = checksum(file)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		err = m.publish(ctx, pubsub.FileChunkEvent, chunk)
+
+		if err != nil {
+			return err
+		}
+
+		if chunk.EOF {
+			return nil
+		}
+
+		offset += int64(n)
+	}
+}
+
+// HandleAck registra o offset confirmado pelo servidor e libera o envio dos
+// próximos blocos do download.
+func (m *Manager) HandleAck(ctx context.Context, payload pubsub.FileAckPayload) {
+	m.getsMu.Lock()
+	d, ok := m.gets[payload.ID]
+	m.getsMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	d.acked = max(d.acked, payload.Offset)
+	d.mu.Unlock()
+
+	select {
+	case d.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) startDownload(id string, offset int64) (*download, error) {
+	m.getsMu.Lock()
+	defer m.getsMu.Unlock()
+
+	if _, exists := m.gets[id]; exists {
+		return nil, fmt.Errorf("transferência já em andamento: %s", id)
+	}
+
+	d := &download{
+		acked:  offset,
+		signal: make(chan struct{}, 1),
+	}
+
+	m.gets[id] = d
+
+	return d, nil
+}
+
+func (m *Manager) endDownload(id string) {
+	m.getsMu.Lock()
+	delete(m.gets, id)
+	m.getsMu.Unlock()
+}
+
+// wait bloqueia enquanto os bytes enviados e não confirmados a partir de
+// offset ocuparem toda a janela. Retorna ErrAckTimeout se o servidor ficar
+// timeout sem confirmar nenhum bloco.
+func (d *download) wait(ctx context.Context, offset int64, window int64, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		d.mu.Lock()
+		inFlight := offset - d.acked
+		d.mu.Unlock()
+
+		if inFlight < window {
+			return nil
+		}
+
+		select {
+		case <-d.signal:
+			timer.Reset(timeout)
+		case <-timer.C:
+			return ErrAckTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *Manager) HandlePut(ctx context.Context, payload pubsub.FilePutPayload) {
+	m.putMu.Lock()
+	ack, err := m.put(payload)
+	m.putMu.Unlock()
+
+	if err != nil {
+		ack.Error = err.Error()
+	}
+
+	m.publish(ctx, pubsub.FileAckEvent, ack)
+}
+
+func (m *Manager) put(payload pubsub.FilePutPayload) (pubsub.FileAckPayload, error) {
+	ack := pubsub.FileAckPayload{
+		ID:   payload.ID,
+		Path: payload.Path,
+	}
+
+	if len(payload.Data) > m.cfg.ChunkSize {
+		return ack, fmt.Errorf("bloco maior que o permitido: %d bytes", len(payload.Data))
+	}
+
+	root, err := m.openRoot()
+
+	if err != nil {
+		return ack, err
+	}
+
+	defer root.Close()
+
+	target := relPath(payload.Path)
+
+	if target == "." {
+		return ack, fmt.Errorf("caminho inválido: %s", payload.Path)
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+
+	if payload.Offset == 0 {
+		flags |= os.O_TRUNC
+	}
+
+	err = root.MkdirAll(filepath.Dir(target), 0755)
+
+	if err != nil {
+		return ack, err
+	}
+
+	file, err := root.OpenFile(target+partSuffix, flags, 0644)
+
+	if err != nil {
+		return ack, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return ack, err
+	}
+
+	// Blocos fora de ordem são recusados e o servidor retoma do offset atual
+	if payload.Offset != info.Size() {
+		ack.Offset = info.Size()
+		return ack, fmt.Errorf("offset inesperado: %d", payload.Offset)
+	}
+
+	n, err := file.WriteAt(payload.Data, payload.Offset)
+
+	ack.Offset = payload.Offset + int64(n)
+
+	if err != nil {
+		return ack, err
+	}
+
+	if !payload.EOF {
+		return ack, nil
+	}
+
+	sum, err := checksum(file)
+
+	if err != nil {
+		return ack, err
+	}
+
+	ack.Checksum = sum
+
+	if payload.Checksum != "" && !strings.EqualFold(payload.Checksum, sum) {
+		file.Close()
+		root.Remove(target + partSuffix)
+		ack.Offset = 0
+		return ack, fmt.Errorf("checksum divergente: esperado %s, obtido %s", payload.Checksum, sum)
+	}
+
+	err = file.Close()
+
+	if err != nil {
+		return ack, err
+	}
+
+	err = root.Rename(target+partSuffix, target)
+
+	if err != nil {
+		return ack, err
+	}
+
+	ack.Done = true
+
+	return ack, nil
+}
+
+func (m *Manager) HandleList(ctx context.Context, payload pubsub.FileListPayload) {
+	result := pubsub.FileEntriesPayload{
+		ID:   payload.ID,
+		Path: payload.Path,
+	}
+
+	entries, err := m.list(payload.Path)
+
+	result.Entries = entries
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	m.publish(ctx, pubsub.FileEntriesEvent, result)
+}
+
+func (m *Manager) list(p string) ([]pubsub.FileEntry, error) {
+	root, err := m.openRoot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer root.Close()
+
+	dir, err := root.Open(relPath(p))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer dir.Close()
+
+	dirEntries, err := dir.ReadDir(-1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]pubsub.FileEntry, 0, len(dirEntries))
+
+	for _, entry := range dirEntries {
+		info, err := entry.Info()
+
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, pubsub.FileEntry{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+			Dir:     entry.IsDir(),
+		})
+	}
+
+	return entries, nil
+}
+
+func (m *Manager) publish(ctx context.Context, event string, payload any) error {
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	err = m.ps.PublishWait(ctx, event, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar transferência de arquivo:", err)
+	}
+
+	return err
+}
+
+func checksum(file *os.File) (string, error) {
+	hash := sha256.New()
+
+	_, err := io.Copy(hash, io.NewSectionReader(file, 0, 1<<62))
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
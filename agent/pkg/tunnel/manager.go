@@ -0,0 +1,312 @@
+package tunnel
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrDisabled = errors.New("túneis desabilitados")
+
+var errDataOverflow = errors.New("dados do túnel descartados: fila de processamento cheia")
+
+type Manager struct {
+	ps      *pubsub.PubSub
+	cfg     config.TunnelConfig
+	streams map[string]*stream
+	mu      sync.RWMutex
+}
+
+func NewManager(ps *pubsub.PubSub, cfg config.TunnelConfig) *Manager {
+	return &Manager{
+		ps:      ps,
+		cfg:     cfg,
+		streams: make(map[string]*stream),
+	}
+}
+
+// targetAllowed verifica se o destino corresponde a algum item da allowlist.
+func (m *Manager) targetAllowed(target string) bool {
+	host, port, err := net.SplitHostPort(target)
+
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range m.cfg.Allow {
+		allowedHost, allowedPort, err := net.SplitHostPort(allowed)
+
+		if err != nil {
+			continue
+		}
+
+		if allowedHost != host {
+			continue
+		}
+
+		if allowedPort == "*" || allowedPort == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Manager) HandleOpen(ctx context.Context, payload pubsub.TunnelOpenPayload) {
+	opened := pubsub.TunnelOpenedPayload{
+		StreamID: payload.StreamID,
+		Window:   m.cfg.Window,
+	}
+
+	err := m.open(ctx, payload)
+
+	if err != nil {
+		opened.Error = err.Error()
+	}
+
+	m.publish(ctx, pubsub.TunnelOpenedEvent, opened)
+}
+
+func (m *Manager) open(ctx context.Context, payload pubsub.TunnelOpenPayload) error {
+	if !m.cfg.Enabled {
+		return ErrDisabled
+	}
+
+	if !m.targetAllowed(payload.Target) {
+		return fmt.Errorf("destino não permitido: %s", payload.Target)
+	}
+
+	// Reserva o id antes de conectar para que dois open simultâneos com o
+	// mesmo streamId não abram duas conexões
+	m.mu.Lock()
+	_, exists := m.streams[payload.StreamID]
+
+	if !exists {
+		m.streams[payload.StreamID] = nil
+	}
+
+	m.mu.Unlock()
+
+	if exists {
+		return fmt.Errorf("stream já existe: %s", payload.StreamID)
+	}
+
+	dialer := net.Dialer{Timeout: time.Duration(m.cfg.DialTimeout) * time.Second}
+
+	conn, err := dialer.DialContext(ctx, "tcp", payload.Target)
+
+	if err != nil {
+		m.mu.Lock()
+		delete(m.streams, payload.StreamID)
+		m.mu.Unlock()
+
+		return err
+	}
+
+	credit := payload.Window
+
+	if credit <= 0 {
+		credit = m.cfg.Window
+	}
+
+	s := newStream(ctx, payload.StreamID, conn, credit, m.cfg.Window)
+
+	m.mu.Lock()
+	m.streams[s.id] = s
+	m.mu.Unlock()
+
+	go m.readLoop(s)
+	go m.writeLoop(s)
+
+	return nil
+}
+
+// readLoop envia ao servidor os dados lidos da conexão local, limitado pelo
+// crédito concedido.
+func (m *Manager) readLoop(s *stream) {
+	buf := make([]byte, chunkSize)
+
+	for {
+		n, ok := s.takeCredit(chunkSize)
+
+		if !ok {
+			return
+		}
+
+		read, err := s.conn.Read(buf[:n])
+
+		s.returnCredit(n - int64(read))
+
+		if read > 0 {
+			s.sendSeq++
+
+			pubErr := m.publish(s.ctx, pubsub.TunnelDataEvent, pubsub.TunnelDataPayload{
+				StreamID: s.id,
+				Seq:      s.sendSeq,
+				Data:     buf[:read],
+			})
+
+			if pubErr != nil {
+				m.closeStream(s, pubErr, true)
+				return
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+
+			m.closeStream(s, err, true)
+			return
+		}
+	}
+}
+
+// writeLoop escreve na conexão local os blocos recebidos e devolve crédito
+// ao servidor conforme são consumidos.
+func (m *Manager) writeLoop(s *stream) {
+	for {
+		data, ok := s.dequeue()
+
+		if !ok {
+			select {
+			case <-s.writeSignal:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		_, err := s.conn.Write(data)
+
+		if err != nil {
+			m.closeStream(s, err, true)
+			return
+		}
+
+		m.publish(s.ctx, pubsub.TunnelAckEvent, pubsub.TunnelAckPayload{
+			StreamID: s.id,
+			Bytes:    int64(len(data)),
+		})
+	}
+}
+
+func (m *Manager) HandleData(ctx context.Context, payload pubsub.TunnelDataPayload) {
+	s, ok := m.stream(payload.StreamID)
+
+	if !ok {
+		return
+	}
+
+	err := s.enqueue(payload.Seq, payload.Data)
+
+	if err != nil {
+		m.closeStream(s, err, true)
+	}
+}
+
+// HandleDataOverflow encerra o stream cujos dados foram descartados pelo
+// dispatcher. Não bloqueia.
+func (m *Manager) HandleDataOverflow(ctx context.Context, payload pubsub.TunnelDataPayload) {
+	if s, ok := m.stream(payload.StreamID); ok {
+		go m.closeStream(s, errDataOverflow, true)
+	}
+}
+
+func (m *Manager) HandleAck(ctx context.Context, payload pubsub.TunnelAckPayload) {
+	if s, ok := m.stream(payload.StreamID); ok {
+		s.addCredit(payload.Bytes)
+	}
+}
+
+func (m *Manager) HandleClose(ctx context.Context, payload pubsub.TunnelClosePayload) {
+	if s, ok := m.stream(payload.StreamID); ok {
+		m.closeStream(s, nil, false)
+	}
+}
+
+// CloseAll encerra todos os túneis abertos.
+func (m *Manager) CloseAll() {
+	m.closeAll(true)
+}
+
+// OnDisconnect encerra os túneis da conexão que terminou. O servidor não
+// mantém os streams entre conexões, então não há a quem avisar.
+func (m *Manager) OnDisconnect() {
+	m.closeAll(false)
+}
+
+func (m *Manager) closeAll(announce bool) {
+	m.mu.RLock()
+	streams := make([]*stream, 0, len(m.streams))
+
+	for _, s := range m.streams {
+		if s != nil {
+			streams = append(streams, s)
+		}
+	}
+
+	m.mu.RUnlock()
+
+	for _, s := range streams {
+		m.closeStream(s, nil, announce)
+	}
+}
+
+func (m *Manager) stream(id string) (*stream, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Ids reservados durante a conexão ainda não têm stream
+	s := m.streams[id]
+
+	return s, s != nil
+}
+
+// closeStream fecha a conexão local e, se announce for verdadeiro, avisa o
+// servidor do encerramento.
+func (m *Manager) closeStream(s *stream, reason error, announce bool) {
+	if !s.close() {
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.streams, s.id)
+	m.mu.Unlock()
+
+	if !announce {
+		return
+	}
+
+	payload := pubsub.TunnelClosePayload{StreamID: s.id}
+
+	if reason != nil {
+		payload.Error = reason.Error()
+	}
+
+	m.publish(context.Background(), pubsub.TunnelCloseEvent, payload)
+}
+
+func (m *Manager) publish(ctx context.Context, event string, payload any) error {
+	data, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	err = m.ps.PublishWait(ctx, event, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar dados do túnel:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,156 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Tamanho máximo de cada bloco lido da conexão local
+const chunkSize = 16 << 10
+
+var errWindowExceeded = errors.New("janela de recebimento excedida")
+
+type stream struct {
+	id   string
+	conn net.Conn
+	// Cancelado quando o stream é fechado
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// Bytes que ainda podem ser enviados ao servidor sem confirmação
+	credit       int64
+	creditSignal chan struct{}
+	// Blocos recebidos do servidor aguardando escrita, indexados por seq
+	pending      map[int64][]byte
+	pendingBytes int64
+	nextSeq      int64
+	window       int64
+	writeSignal  chan struct{}
+
+	sendSeq   int64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newStream(ctx context.Context, id string, conn net.Conn, credit int64, window int64) *stream {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &stream{
+		id:           id,
+		conn:         conn,
+		ctx:          ctx,
+		cancel:       cancel,
+		credit:       credit,
+		creditSignal: make(chan struct{}, 1),
+		pending:      make(map[int64][]byte),
+		nextSeq:      1,
+		window:       window,
+		writeSignal:  make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+}
+
+func notify(signal chan struct{}) {
+	select {
+	case signal <- struct{}{}:
+	default:
+	}
+}
+
+// close finaliza o stream e retorna false se ele já estava fechado.
+func (s *stream) close() bool {
+	closed := false
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.cancel()
+		s.conn.Close()
+		closed = true
+	})
+
+	return closed
+}
+
+func (s *stream) addCredit(n int64) {
+	s.mu.Lock()
+	s.credit += n
+	s.mu.Unlock()
+
+	notify(s.creditSignal)
+}
+
+// takeCredit bloqueia até que exista crédito disponível e reserva até max
+// bytes para envio.
+func (s *stream) takeCredit(max int64) (int64, bool) {
+	for {
+		s.mu.Lock()
+		available := min(s.credit, max)
+
+		if available > 0 {
+			s.credit -= available
+			s.mu.Unlock()
+			return available, true
+		}
+
+		s.mu.Unlock()
+
+		select {
+		case <-s.creditSignal:
+		case <-s.done:
+			return 0, false
+		}
+	}
+}
+
+func (s *stream) returnCredit(n int64) {
+	if n > 0 {
+		s.addCredit(n)
+	}
+}
+
+// enqueue guarda um bloco recebido do servidor respeitando a janela anunciada.
+// Blocos repetidos, já entregues ou ainda pendentes, são ignorados.
+func (s *stream) enqueue(seq int64, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seq < s.nextSeq {
+		return nil
+	}
+
+	if _, ok := s.pending[seq]; ok {
+		return nil
+	}
+
+	if s.pendingBytes+int64(len(data)) > s.window {
+		return errWindowExceeded
+	}
+
+	s.pending[seq] = data
+	s.pendingBytes += int64(len(data))
+
+	notify(s.writeSignal)
+
+	return nil
+}
+
+// dequeue retorna o próximo bloco na ordem de seq, se já tiver chegado.
+func (s *stream) dequeue() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.pending[s.nextSeq]
+
+	if !ok {
+		return nil, false
+	}
+
+	delete(s.pending, s.nextSeq)
+	s.nextSeq++
+	s.pendingBytes -= int64(len(data))
+
+	return data, true
+}
@@ -0,0 +1,117 @@
+package update
+
+import (
+	"agent/pkg/version"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Sufixos dos arquivos mantidos ao lado do executável durante a atualização
+const (
+	backupSuffix = ".old"
+	// Fica fora do diretório de dados para ser atualizado antes de a
+	// configuração ser carregada
+	attemptsSuffix = ".attempts"
+)
+
+// attempts conta as inicializações da nova versão sem conectar ao servidor.
+type attempts struct {
+	Versao string `json:"versao"`
+	Count  int    `json:"count"`
+}
+
+func executablePath() (string, error) {
+	executable, err := os.Executable()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(executable)
+}
+
+func loadAttempts(executable string) (*attempts, error) {
+	data, err := os.ReadFile(executable + attemptsSuffix)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var a attempts
+
+	err = json.Unmarshal(data, &a)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func saveAttempts(executable string, a *attempts) error {
+	data, err := json.Marshal(a)
+
+	if err != nil {
+		return err
+	}
+
+	path := executable + attemptsSuffix
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// CountStart registra uma inicialização da versão recém-instalada e, passado
+// o limite de tentativas, restaura o binário anterior. Deve ser a primeira
+// ação do start, para que uma versão que falha antes de chegar ao Startup
+// (ex: erro ao carregar a configuração ou panic) também seja revertida.
+func CountStart() {
+	executable, err := executablePath()
+
+	if err != nil {
+		return
+	}
+
+	a, err := loadAttempts(executable)
+
+	if err != nil || a == nil || a.Versao != version.Version {
+		return
+	}
+
+	a.Count++
+
+	err = saveAttempts(executable, a)
+
+	if err != nil {
+		fmt.Println("Erro ao registrar inicialização da atualização:", err)
+	}
+
+	if a.Count <= maxAttempts {
+		return
+	}
+
+	fmt.Printf("Revertendo atualização: a nova versão foi iniciada %d vezes sem conectar ao servidor\n", maxAttempts)
+
+	os.Remove(executable + attemptsSuffix)
+
+	// A versão anterior encontra o estado pendente de outra versão e
+	// publica a falha
+	err = revert(executable)
+
+	if err != nil {
+		fmt.Println("Erro ao restaurar a versão anterior:", err)
+	}
+}
@@ -0,0 +1,149 @@
+package update
+
+import (
+	"agent/pkg/proxy"
+	"agent/pkg/pubsub"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Tempo limite da execução de `vrdeploy version` no binário baixado
+const checkTimeout = 15 * time.Second
+
+// signedMessage retorna o conteúdo assinado pelo servidor: a versão, a
+// plataforma e o SHA-256 do binário, impedindo que um binário assinado seja
+// reaproveitado em outra versão ou plataforma.
+func signedMessage(versao string, sum string) []byte {
+	return fmt.Appendf(nil, "%s\n%s/%s\n%s", versao, runtime.GOOS, runtime.GOARCH, strings.ToLower(sum))
+}
+
+// download baixa o binário para path, verificando o SHA-256 e a assinatura.
+func download(ctx context.Context, payload pubsub.AgenteUpdatePayload, path string, publicKey ed25519.PublicKey) error {
+	expected, err := hex.DecodeString(strings.ToLower(payload.Sha256))
+
+	if err != nil || len(expected) != sha256.Size {
+		return errors.New("sha256 inválido")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(payload.Assinatura)
+
+	if err != nil {
+		return errors.New("assinatura inválida")
+	}
+
+	// A assinatura é verificada antes do download para não baixar binários
+	// que seriam recusados
+	if !ed25519.Verify(publicKey, signedMessage(payload.Versao, payload.Sha256), sig) {
+		return errors.New("assinatura do binário não confere")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, payload.Url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := proxy.Client().Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("erro ao baixar binário: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	file.Close()
+
+	if err == nil && !bytes.Equal(hash.Sum(nil), expected) {
+		err = errors.New("sha256 do binário não confere")
+	}
+
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// check executa o binário baixado e confirma que ele reporta a versão
+// esperada, descartando binários corrompidos ou de outra arquitetura.
+func check(ctx context.Context, path string, expected string) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "version").Output()
+
+	if err != nil {
+		return fmt.Errorf("erro ao executar o novo binário: %w", err)
+	}
+
+	if got := strings.TrimSpace(string(output)); got != expected {
+		return fmt.Errorf("o novo binário reportou a versão %q, esperado %q", got, expected)
+	}
+
+	return nil
+}
+
+// copyFile copia src para dst com as permissões informadas.
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+
+	if err == nil {
+		err = out.Sync()
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(dst)
+	}
+
+	return err
+}
@@ -0,0 +1,65 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Tempo aguardado pelo helper até o agente finalizar
+const helperWaitTimeout = 2 * time.Minute
+
+// HelperOptions são os argumentos do helper que troca o binário no
+// Windows, onde o executável em uso não pode ser sobrescrito.
+type HelperOptions struct {
+	// Processo do agente que precisa finalizar antes da troca
+	Pid int32
+	// Binário copiado para Target
+	Source string
+	Target string
+	// Serviço iniciado após a troca. Vazio inicia o agente como processo
+	Service string
+}
+
+// waitExit aguarda o processo finalizar.
+func waitExit(pid int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), helperWaitTimeout)
+	defer cancel()
+
+	for {
+		exists, err := process.PidExistsWithContext(ctx, pid)
+
+		if err != nil || !exists {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("tempo esgotado aguardando o agente finalizar")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// replace copia source para um arquivo temporário ao lado de target e o
+// renomeia sobre target.
+func replace(source string, target string) error {
+	next := target + ".new"
+
+	err := copyFile(source, next, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(next, target)
+
+	if err != nil {
+		os.Remove(next)
+	}
+
+	return err
+}
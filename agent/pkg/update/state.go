@@ -0,0 +1,74 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Status usados apenas no estado local
+const (
+	// Binário trocado, aguardando a nova versão conectar ao servidor
+	statusPendente = "pendente"
+)
+
+// state registra a atualização em andamento, permitindo que a nova versão
+// confirme ou desfaça a troca ao iniciar.
+type state struct {
+	Versao     string `json:"versao"`
+	Anterior   string `json:"anterior"`
+	Executable string `json:"executable"`
+	Backup     string `json:"backup"`
+	Status     string `json:"status"`
+	Erro       string `json:"erro,omitempty"`
+	// Prazo para a nova versão conectar, definido na primeira inicialização
+	Deadline time.Time `json:"deadline,omitzero"`
+}
+
+func loadState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+
+	err = json.Unmarshal(data, &st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+func saveState(path string, st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, data, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
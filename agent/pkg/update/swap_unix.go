@@ -0,0 +1,84 @@
+//go:build !windows
+
+package update
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// swap substitui o executável pelo binário baixado com um rename, que é
+// atômico e permitido com o binário em execução.
+func (u *Updater) swap(staged string, st *state) error {
+	// O rename só é atômico no mesmo sistema de arquivos do executável
+	next := st.Executable + ".new"
+
+	err := copyFile(staged, next, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	os.Remove(st.Backup)
+
+	err = os.Link(st.Executable, st.Backup)
+
+	if err != nil {
+		err = copyFile(st.Executable, st.Backup, 0755)
+	}
+
+	if err != nil {
+		os.Remove(next)
+		return err
+	}
+
+	err = saveState(u.statePath(), st)
+
+	if err != nil {
+		os.Remove(next)
+		return err
+	}
+
+	err = os.Rename(next, st.Executable)
+
+	if err != nil {
+		os.Remove(next)
+		os.Remove(u.statePath())
+		return err
+	}
+
+	os.Remove(staged)
+
+	return nil
+}
+
+func (u *Updater) restore(st *state) error {
+	return os.Rename(st.Backup, st.Executable)
+}
+
+// revert restaura o backup sobre o executável e inicia o binário anterior
+// no lugar do processo atual. Usado antes de a configuração ser carregada.
+func revert(executable string) error {
+	err := os.Rename(executable+backupSuffix, executable)
+
+	if err != nil {
+		return err
+	}
+
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
+
+// Relaunch substitui o processo atual pelo binário do executável, mantendo
+// o PID acompanhado pelo systemd, OpenRC ou SysV.
+func (u *Updater) Relaunch() error {
+	if u.executable == "" {
+		return errors.New("caminho do executável desconhecido")
+	}
+
+	return syscall.Exec(u.executable, os.Args, os.Environ())
+}
+
+func RunHelper(opts HelperOptions) error {
+	return errors.New("o helper de atualização é usado apenas no Windows")
+}
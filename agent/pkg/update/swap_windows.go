@@ -0,0 +1,188 @@
+//go:build windows
+
+package update
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Nome do serviço instalado pelo `vrdeploy service install`
+const serviceName = "vrdeploy"
+
+// swap copia o executável atual para o backup e inicia o helper a partir
+// do binário baixado. O helper aguarda o agente finalizar para trocar o
+// executável, que não pode ser sobrescrito enquanto está em uso.
+func (u *Updater) swap(staged string, st *state) error {
+	err := copyFile(st.Executable, st.Backup, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	err = saveState(u.statePath(), st)
+
+	if err != nil {
+		return err
+	}
+
+	err = spawnHelper(staged, staged, st.Executable)
+
+	if err != nil {
+		os.Remove(u.statePath())
+		return err
+	}
+
+	return nil
+}
+
+// restore inicia o helper para copiar o backup sobre o executável. O helper
+// é uma cópia do binário atual, já que o backup pode ser de uma versão que
+// não possui o helper.
+func (u *Updater) restore(st *state) error {
+	helper := filepath.Join(u.dir, "helper.exe")
+
+	err := copyFile(u.executable, helper, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	return spawnHelper(helper, st.Backup, st.Executable)
+}
+
+// revert inicia o helper para restaurar o backup e finaliza o processo. O
+// helper inicia o agente novamente após a troca. Usado antes de a
+// configuração ser carregada, por isso o helper fica no diretório
+// temporário.
+func revert(executable string) error {
+	helper := filepath.Join(os.TempDir(), "vrdeploy-helper.exe")
+
+	err := copyFile(executable, helper, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	err = spawnHelper(helper, executable+backupSuffix, executable)
+
+	if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+
+	return nil
+}
+
+// Relaunch não tem efeito no Windows: o helper inicia o agente após a troca.
+func (u *Updater) Relaunch() error {
+	return nil
+}
+
+func spawnHelper(helper string, source string, target string) error {
+	args := []string{
+		"update-helper",
+		"--pid", strconv.Itoa(os.Getpid()),
+		"--source", source,
+		"--target", target,
+	}
+
+	if isService, err := svc.IsWindowsService(); err == nil && isService {
+		args = append(args, "--service", serviceName)
+	}
+
+	cmd := exec.Command(helper, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP,
+	}
+
+	err := cmd.Start()
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
+
+// RunHelper aguarda o agente finalizar, troca o executável e inicia o
+// agente novamente.
+func RunHelper(opts HelperOptions) error {
+	err := waitExit(opts.Pid)
+
+	if err != nil {
+		return err
+	}
+
+	err = replace(opts.Source, opts.Target)
+
+	if err != nil {
+		return fmt.Errorf("erro ao substituir o executável: %w", err)
+	}
+
+	if opts.Service != "" {
+		return startService(opts.Service)
+	}
+
+	cmd := exec.Command(opts.Target, "start")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.CREATE_NEW_CONSOLE,
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
+
+// startService inicia o serviço, aguardando o fim da parada caso as ações
+// de recuperação ainda não tenham terminado de finalizá-lo.
+func startService(name string) error {
+	m, err := mgr.Connect()
+
+	if err != nil {
+		return err
+	}
+
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	for range 50 {
+		query, err := s.Query()
+
+		if err != nil {
+			return err
+		}
+
+		switch query.State {
+		case svc.Running, svc.StartPending:
+			return nil
+		case svc.Stopped:
+			return s.Start()
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	return fmt.Errorf("o serviço %s não parou a tempo de ser iniciado", name)
+}
@@ -0,0 +1,352 @@
+package update
+
+import (
+	"agent/pkg/config"
+	"agent/pkg/pubsub"
+	"agent/pkg/version"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Inicializações da nova versão sem conectar antes de desfazer a troca,
+// contadas por CountStart para o caso de ela finalizar antes do prazo (ex:
+// panic ou erro ao carregar a configuração)
+const maxAttempts = 3
+
+var validVersion = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+_-]*$`)
+
+// Updater aplica as atualizações do binário do agente enviadas pelo
+// servidor e desfaz a troca quando a nova versão não conecta no prazo.
+type Updater struct {
+	ps  *pubsub.PubSub
+	cfg config.UpdateConfig
+	dir string
+	// Caminho do executável na inicialização. Após a troca, os.Executable
+	// pode apontar para o binário removido
+	executable string
+
+	mu sync.Mutex
+
+	restart     chan struct{}
+	restartOnce sync.Once
+
+	confirmed     chan struct{}
+	confirmedOnce sync.Once
+}
+
+func NewUpdater(ps *pubsub.PubSub, cfg config.UpdateConfig, dataDir string) *Updater {
+	executable, err := executablePath()
+
+	if err != nil {
+		fmt.Println("Erro ao obter caminho do executável:", err)
+	}
+
+	return &Updater{
+		ps:         ps,
+		cfg:        cfg,
+		dir:        filepath.Join(dataDir, "update"),
+		executable: executable,
+		restart:    make(chan struct{}),
+		confirmed:  make(chan struct{}),
+	}
+}
+
+// Restart é fechado quando o agente deve ser finalizado para aplicar ou
+// desfazer uma atualização. Após o encerramento, Relaunch inicia o binário
+// que estiver no caminho do executável.
+func (u *Updater) Restart() <-chan struct{} {
+	return u.restart
+}
+
+func (u *Updater) requestRestart() {
+	u.restartOnce.Do(func() {
+		close(u.restart)
+	})
+}
+
+func (u *Updater) statePath() string {
+	return filepath.Join(u.dir, "state.json")
+}
+
+func (u *Updater) HandleUpdate(ctx context.Context, payload pubsub.AgenteUpdatePayload) {
+	// Sem a capacidade o servidor não receberia o resultado da atualização
+	if !u.ps.Protocol().Has(pubsub.CapabilityUpdate) {
+		fmt.Println("Atualização ignorada: servidor não reconhece a capacidade", pubsub.CapabilityUpdate)
+		return
+	}
+
+	if payload.Versao == version.Version {
+		fmt.Println("Agente já está na versão", payload.Versao)
+		return
+	}
+
+	fmt.Printf("Atualizando o agente da versão %s para %s\n", version.Version, payload.Versao)
+
+	err := u.update(ctx, payload)
+
+	if err == nil {
+		return
+	}
+
+	fmt.Println("Erro ao atualizar o agente:", err)
+
+	u.report(pubsub.AgenteUpdateResultPayload{
+		Versao:   payload.Versao,
+		Anterior: version.Version,
+		Status:   pubsub.AtualizacaoFalha,
+		Erro:     err.Error(),
+	})
+}
+
+func (u *Updater) update(ctx context.Context, payload pubsub.AgenteUpdatePayload) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	publicKey, err := u.publicKey()
+
+	if err != nil {
+		return err
+	}
+
+	if !validVersion.MatchString(payload.Versao) {
+		return fmt.Errorf("versão inválida: %s", payload.Versao)
+	}
+
+	// Builds de desenvolvimento não têm versão comparável
+	if version.Version != "dev" {
+		order, err := version.Compare(payload.Versao, version.Version)
+
+		if err != nil {
+			return err
+		}
+
+		if order < 0 {
+			return fmt.Errorf("versão %s é anterior à atual %s", payload.Versao, version.Version)
+		}
+	}
+
+	current, err := loadState(u.statePath())
+
+	if err != nil {
+		return err
+	}
+
+	if current != nil && current.Status == statusPendente {
+		return errors.New("já existe uma atualização em andamento")
+	}
+
+	if u.executable == "" {
+		return errors.New("caminho do executável desconhecido")
+	}
+
+	err = os.MkdirAll(u.dir, 0700)
+
+	if err != nil {
+		return err
+	}
+
+	staged := filepath.Join(u.dir, "vrdeploy-"+payload.Versao+filepath.Ext(u.executable))
+
+	err = download(ctx, payload, staged, publicKey)
+
+	if err != nil {
+		return fmt.Errorf("erro ao baixar binário: %w", err)
+	}
+
+	err = check(ctx, staged, payload.Versao)
+
+	if err != nil {
+		os.Remove(staged)
+		return err
+	}
+
+	st := &state{
+		Versao:     payload.Versao,
+		Anterior:   version.Version,
+		Executable: u.executable,
+		Backup:     u.executable + backupSuffix,
+		Status:     statusPendente,
+	}
+
+	// Contador lido pela nova versão antes de qualquer outra etapa do start
+	err = saveAttempts(u.executable, &attempts{Versao: payload.Versao})
+
+	if err != nil {
+		os.Remove(staged)
+		return err
+	}
+
+	err = u.swap(staged, st)
+
+	if err != nil {
+		os.Remove(staged)
+		os.Remove(u.executable + attemptsSuffix)
+		return err
+	}
+
+	fmt.Println("Nova versão instalada, reiniciando o agente")
+
+	u.requestRestart()
+
+	return nil
+}
+
+func (u *Updater) publicKey() (ed25519.PublicKey, error) {
+	if u.cfg.PublicKey == "" {
+		return nil, errors.New("chave pública de atualização não configurada")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(u.cfg.PublicKey)
+
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("chave pública de atualização inválida")
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// Startup verifica se o agente acabou de ser atualizado, acompanhando a
+// nova versão até ela conectar ou o prazo terminar.
+func (u *Updater) Startup(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	st, err := loadState(u.statePath())
+
+	if err != nil {
+		fmt.Println("Erro ao ler estado da atualização:", err)
+		return
+	}
+
+	if st == nil || st.Status != statusPendente {
+		return
+	}
+
+	// A troca não teve efeito (ex: o helper falhou no Windows) ou foi
+	// desfeita por CountStart
+	if st.Versao != version.Version {
+		u.finish(st, pubsub.AtualizacaoFalha, "a nova versão não foi iniciada ou não conectou ao servidor")
+		return
+	}
+
+	if st.Deadline.IsZero() {
+		st.Deadline = time.Now().Add(time.Duration(u.cfg.HealthTimeout) * time.Second)
+
+		err = saveState(u.statePath(), st)
+
+		if err != nil {
+			fmt.Println("Erro ao gravar estado da atualização:", err)
+		}
+	}
+
+	if time.Now().After(st.Deadline) {
+		u.rollback(st, "a nova versão não conectou ao servidor")
+		return
+	}
+
+	go u.watch(ctx, st.Deadline)
+}
+
+func (u *Updater) watch(ctx context.Context, deadline time.Time) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-u.confirmed:
+	case <-timer.C:
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		st, err := loadState(u.statePath())
+
+		if err != nil || st == nil || st.Status != statusPendente {
+			return
+		}
+
+		u.rollback(st, "a nova versão não conectou ao servidor dentro do prazo")
+	}
+}
+
+// OnConnect confirma a atualização pendente: a nova versão conectou ao
+// servidor.
+func (u *Updater) OnConnect(ctx context.Context) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	st, err := loadState(u.statePath())
+
+	if err != nil || st == nil || st.Status != statusPendente || st.Versao != version.Version {
+		return
+	}
+
+	u.confirmedOnce.Do(func() {
+		close(u.confirmed)
+	})
+
+	fmt.Println("Atualização para a versão", st.Versao, "concluída")
+
+	u.finish(st, pubsub.AtualizacaoConcluida, "")
+}
+
+// rollback volta para o binário anterior e reinicia o agente. O resultado
+// é publicado antes, já que a versão anterior pode não conhecer o estado
+// da atualização.
+func (u *Updater) rollback(st *state, reason string) {
+	fmt.Println("Revertendo atualização:", reason)
+
+	err := u.restore(st)
+
+	if err != nil {
+		fmt.Println("Erro ao restaurar a versão anterior:", err)
+		u.finish(st, pubsub.AtualizacaoFalha, reason+"; erro ao restaurar a versão anterior: "+err.Error())
+		return
+	}
+
+	u.finish(st, pubsub.AtualizacaoRevertida, reason)
+	u.requestRestart()
+}
+
+// finish publica o resultado e remove o estado e os arquivos temporários.
+// O backup é mantido para uma reversão manual.
+func (u *Updater) finish(st *state, status string, erro string) {
+	u.report(pubsub.AgenteUpdateResultPayload{
+		Versao:   st.Versao,
+		Anterior: st.Anterior,
+		Status:   status,
+		Erro:     erro,
+	})
+
+	os.Remove(st.Executable + attemptsSuffix)
+
+	entries, _ := os.ReadDir(u.dir)
+
+	for _, entry := range entries {
+		os.Remove(filepath.Join(u.dir, entry.Name()))
+	}
+}
+
+func (u *Updater) report(result pubsub.AgenteUpdateResultPayload) {
+	data, err := json.Marshal(result)
+
+	if err != nil {
+		fmt.Println("Erro ao serializar resultado da atualização:", err)
+		return
+	}
+
+	// Durável: o agente reinicia logo após a atualização
+	err = u.ps.PublishDurable(pubsub.AgenteUpdateResultEvent, string(data))
+
+	if err != nil {
+		fmt.Println("Erro ao publicar resultado da atualização:", err)
+	}
+}
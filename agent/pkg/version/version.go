@@ -0,0 +1,76 @@
+package version
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version é a versão do agente, definida no build com
+// -ldflags "-X agent/pkg/version.Version=1.2.3"
+var Version = "dev"
+
+// Compare compara duas versões no formato MAJOR.MINOR.PATCH, com prefixo
+// "v" e sufixos -pre e +build opcionais, retornando -1, 0 ou 1. Uma versão
+// com -pre é anterior à mesma versão sem ele.
+func Compare(a string, b string) (int, error) {
+	coreA, preA, err := parse(a)
+
+	if err != nil {
+		return 0, err
+	}
+
+	coreB, preB, err := parse(b)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range max(len(coreA), len(coreB)) {
+		var x, y int
+
+		if i < len(coreA) {
+			x = coreA[i]
+		}
+
+		if i < len(coreB) {
+			y = coreB[i]
+		}
+
+		if x != y {
+			return cmp.Compare(x, y), nil
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0, nil
+	case preA == "":
+		return 1, nil
+	case preB == "":
+		return -1, nil
+	default:
+		return strings.Compare(preA, preB), nil
+	}
+}
+
+func parse(v string) ([]int, string, error) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, pre, _ := strings.Cut(v, "-")
+
+	var parts []int
+
+	for _, part := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(part)
+
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("versão inválida: %s", v)
+		}
+
+		parts = append(parts, n)
+	}
+
+	return parts, pre, nil
+}
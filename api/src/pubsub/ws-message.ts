@@ -17,6 +17,8 @@ export const ptyOutputEvent = z.object({
   data: z.string()
 })
 
+// data é o id do agente ou, para agentes que anunciam a capacidade
+// pty-ended, o JSON serializado { idAgente, exitCode, reason? }
 export const ptySessionEndedEvent = z.object({
   type: z.literal('publish'),
   event: z.literal('pty:session_ended'),